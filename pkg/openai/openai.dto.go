@@ -1,5 +1,13 @@
 package openai
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+)
+
 // OPEN AI DOCS api Reference
 // https://platform.openai.com/docs/api-reference/chat/create
 
@@ -9,30 +17,120 @@ package openai
 
 // ----------------- CHAT COMPLETIONS ----------------------
 type OAReqBodyMessageCompletion struct {
-	Messages         interface{}            `json:"messages"` // required
-	Model            string                 `json:"model"`    // required
-	Store            bool                   `json:"store,omitempty"`
-	Metadata         interface{}            `json:"metadata,omitempty"`
+	Messages         interface{}            `json:"messages"`        // required
+	Model            string                 `json:"model"`           // required
+	Store            *bool                  `json:"store,omitempty"` // nil use the OpenAI default, true to retrieve it later (OpenAIRetrieveCompletion), false to opt out
+	Metadata         map[string]string      `json:"metadata,omitempty"`
 	FrequencyPenalty float64                `json:"frequency_penalty,omitempty"`
 	LogitBias        map[string]interface{} `json:"logit_bias,omitempty"`
 	Logprobe         bool                   `json:"logprobe,omitempty"`
 	Modalities       []string               `json:"modalities,omitempty"`
 	ResponseFormat   map[string]interface{} `json:"response_format,omitempty"`
+	// token limit for the completion, reasoning and newer models (o1, o3, o4, gpt-5) only accept max_completion_tokens
+	// and older models only accept max_tokens, set one of them and OpenAISendMessage will send it on the field the model accepts
+	MaxTokens           int `json:"max_tokens,omitempty"`
+	MaxCompletionTokens int `json:"max_completion_tokens,omitempty"`
+	// web search grounding, only for search models (gpt-4o-search-preview and gpt-4o-mini-search-preview)
+	WebSearchOptions *OAWebSearchOptions `json:"web_search_options,omitempty"`
+	// key to improve prompt cache hit for request with same long prefix (e.g. system prompt), the cached tokens is on OAUsage.PromptTokensDetails
+	PromptCacheKey string `json:"prompt_cache_key,omitempty"`
+	// number of choices to generate (1 - 128, default 1), all choices share one usage on the response, see OAChatCompletionResp.CompletionTokensPerChoice
+	N *int `json:"n,omitempty"`
+	// server-sent events streaming, set by OpenAISendMessageStream
+	Stream        bool             `json:"stream,omitempty"`
+	StreamOptions *OAStreamOptions `json:"stream_options,omitempty"`
+	// function tools the model can call, the calls is returned on OAMessage.ToolCalls
+	Tools []OATool `json:"tools,omitempty"`
+	// "none", "auto" (default with tools), "required", or {"type": "function", "function": {"name": "..."}} to force one function
+	ToolChoice interface{} `json:"tool_choice,omitempty"`
+	// sampling setup, nil use the OpenAI default. can also be set for every request with WithSamplingParams
+	Temperature     *float64 `json:"temperature,omitempty"`      // 0 to 2, higher is more random, change Temperature or TopP but not both
+	TopP            *float64 `json:"top_p,omitempty"`            // 0 to 1, nucleus sampling, 0.1 means only the top 10% probability tokens
+	Stop            []string `json:"stop,omitempty"`             // up to 4 sequences where the model stop generating
+	PresencePenalty *float64 `json:"presence_penalty,omitempty"` // -2 to 2, positive value make the model talk about new topics
+	Seed            *int     `json:"seed,omitempty"`             // best effort deterministic sampling, check SystemFingerprint for backend change
+	// log probability of the output tokens on OAChoice.Logprobs (also on the stream chunk), TopLogprobs (0 - 20) need Logprobs true
+	Logprobs    bool `json:"logprobs,omitempty"`
+	TopLogprobs *int `json:"top_logprobs,omitempty"`
+}
+
+// sampling setup for WithSamplingParams (client default) or the params argument of OpenAISendMessage (per call),
+// nil or zero field use the next default. the valid range is the same as OAReqBodyMessageCompletion
+type OASamplingParams struct {
+	Temperature         *float64
+	TopP                *float64
+	MaxTokens           int // token limit, sent on the field the model accepts like OAReqBodyMessageCompletion
+	MaxCompletionTokens int
+	N                   *int
+	Stop                []string
+	PresencePenalty     *float64
+	Seed                *int
+}
+
+// streaming setup, include usage add one last chunk with the usage of the whole request (the choices of the chunk is empty)
+type OAStreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
+}
+
+// web search setup for search models, the url citation used by the model is returned on OAMessage.Annotations
+//   - OpenAI Docs: https://platform.openai.com/docs/guides/tools-web-search?api-mode=chat
+type OAWebSearchOptions struct {
+	SearchContextSize string                   `json:"search_context_size,omitempty"` // low, medium (default), or high
+	UserLocation      *OAWebSearchUserLocation `json:"user_location,omitempty"`
+}
+
+type OAWebSearchUserLocation struct {
+	Type        string                         `json:"type"` // always "approximate"
+	Approximate OAWebSearchApproximateLocation `json:"approximate"`
+}
+
+type OAWebSearchApproximateLocation struct {
+	Country  string `json:"country,omitempty"`  // two letter ISO country code, e.g. "US"
+	City     string `json:"city,omitempty"`     // free text, e.g. "San Francisco"
+	Region   string `json:"region,omitempty"`   // free text, e.g. "California"
+	Timezone string `json:"timezone,omitempty"` // IANA timezone, e.g. "America/Los_Angeles"
 }
 
 type OAMessageReq struct {
 	Role    string      `json:"role"`
 	Content interface{} `json:"content"`
+	// function calls of the assistant message, send the assistant message back as is before the tool result messages
+	ToolCalls []OAToolCall `json:"tool_calls,omitempty"`
+	// call ID answered by the tool result message (role "tool"), use OAAppendToolResult to create it
+	ToolCallID string `json:"tool_call_id,omitempty"`
 }
 
 type OAContentVisionImageUrl struct {
-	Url string `json:"url"`
+	Url    string `json:"url"`
+	Detail string `json:"detail,omitempty"` // low, high, or auto (default), the token cost can be estimated with OAEstimateVisionTokens
 }
 
 type OAContentVisionBaseReq struct {
-	Type     string                   `json:"type"`
-	Text     *string                  `json:"text,omitempty"`
-	ImageUrl *OAContentVisionImageUrl `json:"image_url,omitempty"`
+	Type       string                   `json:"type"`
+	Text       *string                  `json:"text,omitempty"`
+	ImageUrl   *OAContentVisionImageUrl `json:"image_url,omitempty"`
+	InputAudio *OAContentInputAudio     `json:"input_audio,omitempty"` // for type "input_audio", audio model like gpt-4o-audio-preview
+}
+
+// IsImage check if the content part is an image (type "image_url" with image data)
+func (c OAContentVisionBaseReq) IsImage() bool {
+	return c.Type == "image_url" && c.ImageUrl != nil && c.ImageUrl.Url != ""
+}
+
+// ImageData return the image of the content part as sent to OpenAI, the full "data:<media_type>;base64,..." URI for base64 image
+// or the image URL, error if the content part is not an image
+func (c OAContentVisionBaseReq) ImageData() (string, error) {
+	if !c.IsImage() {
+		return "", errors.New("content part is not an image")
+	}
+
+	return c.ImageUrl.Url, nil
+}
+
+// audio input content for audio model
+type OAContentInputAudio struct {
+	Data   string `json:"data"`   // base64 encoded audio data
+	Format string `json:"format"` // "wav" or "mp3"
 }
 
 // response COMPLETION OpenAI structure
@@ -44,13 +142,151 @@ type OAChatCompletionResp struct {
 	SystemFingerprint string     `json:"system_fingerprint"`
 	Choices           []OAChoice `json:"choices"`
 	Usage             OAUsage    `json:"usage"`
+	// metadata of stored completion (request with Store and Metadata), echoed on the retrieve and list stored completions response
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// number of oldest messages dropped to fit the model context window (WithContextTrimming), 0 if the conversation is sent complete
+	TrimmedMessages int `json:"-"`
+}
+
+// Content return the message content of the first choice, error if the response has no choice
+func (r *OAChatCompletionResp) Content() (string, error) {
+	if r == nil || len(r.Choices) == 0 {
+		return "", errors.New("response has no choices")
+	}
+
+	return r.Choices[0].Message.Content, nil
+}
+
+// FinishReason return the finish reason of the first choice (stop, length, content_filter, tool_calls), error if the response has no choice
+func (r *OAChatCompletionResp) FinishReason() (string, error) {
+	if r == nil || len(r.Choices) == 0 {
+		return "", errors.New("response has no choices")
+	}
+
+	return r.Choices[0].FinishReason, nil
+}
+
+// CompletionTokensPerChoice return the estimated completion tokens of each choice, by the choice index order.
+// OpenAI only return one usage for all choices when the request has N > 1, so the completion tokens is split by the content length of each choice
+// (evenly if all content is empty). the result is an estimate, the sum is always equal to Usage.CompletionTokens
+func (r *OAChatCompletionResp) CompletionTokensPerChoice() []int {
+	if r == nil || len(r.Choices) == 0 {
+		return nil
+	}
+
+	lengths := make([]int, len(r.Choices))
+	totalLength := 0
+	for i, choice := range r.Choices {
+		lengths[i] = len([]rune(choice.Message.Content))
+		totalLength += lengths[i]
+	}
+
+	total := r.Usage.CompletionTokens
+	perChoice := make([]int, len(r.Choices))
+	assigned := 0
+	for i := range r.Choices {
+		if totalLength == 0 {
+			perChoice[i] = total / len(r.Choices)
+		} else {
+			perChoice[i] = total * lengths[i] / totalLength
+		}
+		assigned += perChoice[i]
+	}
+
+	// rounding remainder go to the first choices so the sum match the usage
+	for i := 0; assigned < total; i = (i + 1) % len(perChoice) {
+		perChoice[i]++
+		assigned++
+	}
+
+	return perChoice
+}
+
+// ResolvedModel return the concrete model that served the request, for alias (e.g. "gpt-4o") it is the versioned model
+// (e.g. "gpt-4o-2024-08-06") and with WithModelFallback it is the model in the chain that succeeded
+func (r *OAChatCompletionResp) ResolvedModel() string {
+	if r == nil {
+		return ""
+	}
+
+	return r.Model
+}
+
+// TotalTokens return the total tokens (prompt + completion) used by the request
+func (r *OAChatCompletionResp) TotalTokens() int {
+	if r == nil {
+		return 0
+	}
+
+	return r.Usage.TotalTokens
 }
 
 type OAChoice struct {
-	Index        int       `json:"index"`
-	Message      OAMessage `json:"message"`
-	Logprobs     *string   `json:"logprobs"` // Could be null, so pointer
-	FinishReason string    `json:"finish_reason"`
+	Index        int         `json:"index"`
+	Message      OAMessage   `json:"message"`
+	Logprobs     *OALogprobs `json:"logprobs"` // null when the request does not set Logprobs
+	FinishReason string      `json:"finish_reason"`
+	// per category filter results (hate, self_harm, sexual, violence, ...), mostly exist when finish reason is "content_filter".
+	// kept raw because the categories can differ between deployment, use ContentFilter() for the typed result
+	ContentFilterResults json.RawMessage `json:"content_filter_results,omitempty"`
+}
+
+// log probability of the choice tokens in order, Content for the message content and Refusal for the refusal text
+type OALogprobs struct {
+	Content []OATokenLogprob `json:"content"`
+	Refusal []OATokenLogprob `json:"refusal"`
+}
+
+type OATokenLogprob struct {
+	Token       string         `json:"token"`
+	Logprob     float64        `json:"logprob"`      // -9999.0 when the token is very unlikely
+	Bytes       []int          `json:"bytes"`        // UTF-8 bytes of the token, null when the token has no bytes representation
+	TopLogprobs []OATopLogprob `json:"top_logprobs"` // most likely tokens on this position, up to TopLogprobs of the request
+}
+
+type OATopLogprob struct {
+	Token   string  `json:"token"`
+	Logprob float64 `json:"logprob"`
+	Bytes   []int   `json:"bytes"`
+}
+
+// content filter result of one category
+type OAContentFilterResult struct {
+	Filtered bool   `json:"filtered"`
+	Severity string `json:"severity,omitempty"` // safe, low, medium, or high
+	Detected *bool  `json:"detected,omitempty"` // for detection category like jailbreak or protected material
+}
+
+// ContentFilter parse the content filter results of the choice by category name, nil if the response has no filter results
+func (c OAChoice) ContentFilter() (map[string]OAContentFilterResult, error) {
+	if len(c.ContentFilterResults) == 0 || string(c.ContentFilterResults) == "null" {
+		return nil, nil
+	}
+
+	var results map[string]OAContentFilterResult
+	if err := json.Unmarshal(c.ContentFilterResults, &results); err != nil {
+		return nil, errors.New("Failed to decode content filter results: " + err.Error())
+	}
+
+	return results, nil
+}
+
+// FilteredCategories return the category names that filtered the choice content, e.g. ["violence"]
+func (c OAChoice) FilteredCategories() ([]string, error) {
+	results, err := c.ContentFilter()
+	if err != nil {
+		return nil, err
+	}
+
+	var categories []string
+	for category, result := range results {
+		if result.Filtered {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	return categories, nil
 }
 
 type OAMessage struct {
@@ -59,6 +295,72 @@ type OAMessage struct {
 	// support for audio output gpt-4o-audio-preview
 	Refusal string              `json:"refusal,omitempty"`
 	Audio   OAAudioDataResponse `json:"audio,omitempty"`
+	// url citations (web search) or file references (file search) used by the model
+	Annotations []OAAnnotation `json:"annotations,omitempty"`
+	// function calls requested by the model, the finish reason is "tool_calls" and content is empty
+	ToolCalls []OAToolCall `json:"tool_calls,omitempty"`
+}
+
+// MessageReq return the response message as request message, so the assistant answer (with the tool calls) can be added to the conversation
+func (m OAMessage) MessageReq() OAMessageReq {
+	msg := OAMessageReq{
+		Role:      m.Role,
+		Content:   m.Content,
+		ToolCalls: m.ToolCalls,
+	}
+
+	// content is null on tool calls only answer
+	if m.Content == "" && len(m.ToolCalls) > 0 {
+		msg.Content = nil
+	}
+
+	return msg
+}
+
+// function call requested by the model, run the function and send the result back with the call ID
+type OAToolCall struct {
+	ID       string             `json:"id"`
+	Type     string             `json:"type"` // always "function"
+	Function OAToolCallFunction `json:"function"`
+}
+
+type OAToolCallFunction struct {
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON string of the arguments, decode it to the struct used on OABuildTool
+}
+
+// annotation on assistant message, only one of the citation field is filled based on the type
+type OAAnnotation struct {
+	Type         string          `json:"type"` // "url_citation" or "file_citation"
+	URLCitation  *OAURLCitation  `json:"url_citation,omitempty"`
+	FileCitation *OAFileCitation `json:"file_citation,omitempty"`
+}
+
+// start and end index is the position of the cited text on the message content
+type OAURLCitation struct {
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+}
+
+// file reference used by the model when using file search
+type OAFileCitation struct {
+	FileID   string `json:"file_id"`
+	Filename string `json:"filename,omitempty"`
+	Index    int    `json:"index"`
+}
+
+// URLCitations return all url citation on the message in the order returned by the model, can be used to render footnotes on the content
+func (m *OAMessage) URLCitations() []OAURLCitation {
+	var citations []OAURLCitation
+	for _, annotation := range m.Annotations {
+		if annotation.Type == "url_citation" && annotation.URLCitation != nil {
+			citations = append(citations, *annotation.URLCitation)
+		}
+	}
+
+	return citations
 }
 
 type OAAudioDataResponse struct {
@@ -69,16 +371,153 @@ type OAAudioDataResponse struct {
 }
 
 type OAUsage struct {
-	PromptTokens           int          `json:"prompt_tokens"`
-	CompletionTokens       int          `json:"completion_tokens"`
-	TotalTokens            int          `json:"total_tokens"`
-	CompletionTokensDetail TokensDetail `json:"completion_tokens_details"`
+	PromptTokens           int                `json:"prompt_tokens"`
+	CompletionTokens       int                `json:"completion_tokens"`
+	TotalTokens            int                `json:"total_tokens"`
+	CompletionTokensDetail TokensDetail       `json:"completion_tokens_details"`
+	PromptTokensDetails    PromptTokensDetail `json:"prompt_tokens_details"`
+}
+
+// Add return the sum of the usage and other usage including the detail fields, use it to track cumulative usage across conversation turns, example:
+//
+//	var total OAUsage
+//	for _, resp := range responses {
+//	    total = total.Add(resp.Usage)
+//	}
+func (u OAUsage) Add(other OAUsage) OAUsage {
+	return OAUsage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+		CompletionTokensDetail: TokensDetail{
+			ReasoningTokens: u.CompletionTokensDetail.ReasoningTokens + other.CompletionTokensDetail.ReasoningTokens,
+		},
+		PromptTokensDetails: PromptTokensDetail{
+			CachedTokens: u.PromptTokensDetails.CachedTokens + other.PromptTokensDetails.CachedTokens,
+			AudioTokens:  u.PromptTokensDetails.AudioTokens + other.PromptTokensDetails.AudioTokens,
+		},
+	}
+}
+
+// cached tokens is the prompt tokens served from prompt cache (billed with discount)
+type PromptTokensDetail struct {
+	CachedTokens int `json:"cached_tokens"`
+	AudioTokens  int `json:"audio_tokens"`
 }
 
 type TokensDetail struct {
 	ReasoningTokens int `json:"reasoning_tokens"`
 }
 
+// OpenAI 4xx and 5xx error response structure
+//   - OpenAI Docs: https://platform.openai.com/docs/guides/error-codes
+type OARespError struct {
+	Error struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    string `json:"code"`  // could be null, e.g. "content_policy_violation", "rate_limit_exceeded"
+		Param   string `json:"param"` // could be null, request field that caused the error, e.g. "messages[0].content"
+	} `json:"error"`
+}
+
+// query for listing stored chat completions (completion created with Store true)
+//   - OpenAI Docs: https://platform.openai.com/docs/api-reference/chat/list
+type OAListCompletionsReq struct {
+	After    string            // identifier for the last completion from previous page
+	Limit    int               // number of completion to retrieve, default 20
+	Order    string            // "asc" or "desc" (default) by timestamp
+	Model    string            // filter by model used
+	Metadata map[string]string // filter by metadata key and value
+}
+
+// list of stored chat completions
+type OAChatCompletionListResp struct {
+	Object  string                 `json:"object"` // "list"
+	Data    []OAChatCompletionResp `json:"data"`
+	FirstID string                 `json:"first_id"`
+	LastID  string                 `json:"last_id"`
+	HasMore bool                   `json:"has_more"`
+}
+
+// one line (request) on Batch API input file
+//   - OpenAI Docs: https://platform.openai.com/docs/guides/batch
+type OABatchRequestItem struct {
+	CustomID string                     `json:"custom_id"` // required, unique id to match the request with the batch output
+	Method   string                     `json:"method"`    // default "POST"
+	URL      string                     `json:"url"`       // default "/v1/chat/completions"
+	Body     OAReqBodyMessageCompletion `json:"body"`
+}
+
+// ----------------- CHAT COMPLETIONS STREAMING ------ Reference for Chat Completion Chunk
+//   - OpenAI Docs: https://platform.openai.com/docs/api-reference/chat-streaming/streaming
+type OAChatCompletionChunk struct {
+	ID                string          `json:"id"`
+	Object            string          `json:"object"` // "chat.completion.chunk"
+	Created           int64           `json:"created"`
+	Model             string          `json:"model"`
+	SystemFingerprint string          `json:"system_fingerprint"`
+	Choices           []OAChunkChoice `json:"choices"`
+	Usage             *OAUsage        `json:"usage"` // only on the last chunk when StreamOptions.IncludeUsage is true, could be null
+	// stream error (transport, decode, or error event from OpenAI), the chunk with error is the last chunk of the stream
+	Err error `json:"-"`
+}
+
+type OAChunkChoice struct {
+	Index        int          `json:"index"`
+	Delta        OAChunkDelta `json:"delta"`
+	Logprobs     *OALogprobs  `json:"logprobs"`      // log probability of the tokens on this delta, OACollectStream join them on the choice
+	FinishReason string       `json:"finish_reason"` // null until the last chunk of the choice (stop, length, content_filter, tool_calls)
+}
+
+// incremental message of the choice, the full message is all delta of the choice joined together
+type OAChunkDelta struct {
+	Role    string `json:"role,omitempty"` // only on the first chunk
+	Content string `json:"content,omitempty"`
+	Refusal string `json:"refusal,omitempty"` // refusal text of safety refusal, streamed separately from content
+	// function call fragments, the first fragment of a call has the ID and name and the next fragments only add to the arguments
+	ToolCalls []OAToolCallDelta `json:"tool_calls,omitempty"`
+}
+
+// fragment of a streamed function call, fragments with the same Index are one call, use OACollectStream to join them into OAToolCall
+type OAToolCallDelta struct {
+	Index    int                `json:"index"`
+	ID       string             `json:"id,omitempty"`
+	Type     string             `json:"type,omitempty"`
+	Function OAToolCallFunction `json:"function"` // Arguments is a fragment of the JSON string, not a complete JSON
+}
+
+// Content return the content delta of the first choice, empty if the chunk has no choice (e.g. usage chunk)
+func (c *OAChatCompletionChunk) Content() string {
+	if c == nil || len(c.Choices) == 0 {
+		return ""
+	}
+
+	return c.Choices[0].Delta.Content
+}
+
+// Refusal return the refusal delta of the first choice, join all refusal delta to get the full refusal message
+func (c *OAChatCompletionChunk) Refusal() string {
+	if c == nil || len(c.Choices) == 0 {
+		return ""
+	}
+
+	return c.Choices[0].Delta.Refusal
+}
+
+// ----------------- TOOLS (FUNCTION CALLING) ------ Reference for Tool definition
+//   - OpenAI Docs: https://platform.openai.com/docs/guides/function-calling
+type OATool struct {
+	Type     string        `json:"type"` // always "function"
+	Function OAFunctionDef `json:"function"`
+}
+
+type OAFunctionDef struct {
+	Name        string                 `json:"name"` // required, a-z, A-Z, 0-9, underscore, and dash, max 64 characters
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"` // JSON schema of the function arguments, can be created with OABuildTool
+	Strict      bool                   `json:"strict,omitempty"`
+}
+
 // ----------------- DALL E IMAGE GENERATIONS ------ Reference for Image Generation Request Body
 // 	   - OpenAI Docs: https://platform.openai.com/docs/api-reference/images/create
 type OAReqImageGeneratorDallE struct {
@@ -93,6 +532,7 @@ type OAReqImageGeneratorDallE struct {
 }
 
 // response image create DALL e
+// OpenAI does not return the model on image generation response, the model that served the request is the model on the request body
 type OAImageGeneratorDallEResp struct {
 	Created int64                       `json:"created"`
 	Data    []OAImageGeneratorDallEData `json:"data"`
@@ -113,7 +553,248 @@ type OAReqTextToSpeech struct {
 	Speed          *float64 `json:"speed,omitempty"` // optional (0.25 to 4.0. 1.0 is the default.)
 }
 
+// OpenAI does not return the model on speech response (only the audio bytes), the model that served the request is the model on the request body
 type OATextToSpeechResp struct {
 	FormatAudio string `json:"format_audio"` // will be like ".mp3"
 	B64JSON     string `json:"b64_json"`
 }
+
+// Bytes return the raw audio bytes decoded from B64JSON, write it directly to a file or http response with the FormatAudio extension
+func (r *OATextToSpeechResp) Bytes() ([]byte, error) {
+	if r == nil || r.B64JSON == "" {
+		return nil, errors.New("TTS response has no audio data")
+	}
+
+	audio, err := base64.StdEncoding.DecodeString(r.B64JSON)
+	if err != nil {
+		return nil, errors.New("Failed to decode base64 audio: " + err.Error())
+	}
+
+	return audio, nil
+}
+
+// ----------------- MODELS ------ Reference for List Models Response
+//   - OpenAI Docs: https://platform.openai.com/docs/api-reference/models/list
+type OAModelListResp struct {
+	Object string        `json:"object"` // "list"
+	Data   []OAModelData `json:"data"`
+}
+
+type OAModelData struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // "model"
+	Created int64  `json:"created"`
+	OwnedBy string `json:"owned_by"`
+}
+
+// ----------------- ORGANIZATION USAGE ------ Reference for Usage API Response
+//   - OpenAI Docs: https://platform.openai.com/docs/api-reference/usage/completions
+type OAUsageResp struct {
+	Object string          `json:"object"`
+	Data   []OAUsageBucket `json:"data"`
+}
+
+type OAUsageBucket struct {
+	Object    string          `json:"object"`
+	StartTime int64           `json:"start_time"`
+	EndTime   int64           `json:"end_time"`
+	Results   []OAUsageResult `json:"results"`
+}
+
+type OAUsageResult struct {
+	Object            string  `json:"object"`
+	InputTokens       int     `json:"input_tokens"`
+	OutputTokens      int     `json:"output_tokens"`
+	InputCachedTokens int     `json:"input_cached_tokens"`
+	InputAudioTokens  int     `json:"input_audio_tokens"`
+	OutputAudioTokens int     `json:"output_audio_tokens"`
+	NumModelRequests  int     `json:"num_model_requests"`
+	ProjectID         *string `json:"project_id"` // only filled if the usage is grouped by project, so pointer
+	UserID            *string `json:"user_id"`
+	ApiKeyID          *string `json:"api_key_id"`
+	Model             *string `json:"model"`
+	Batch             *bool   `json:"batch"`
+}
+
+// one page response from usage endpoint, the pages is merged into OAUsageResp
+type oaUsagePageResp struct {
+	Object   string          `json:"object"`
+	Data     []OAUsageBucket `json:"data"`
+	HasMore  bool            `json:"has_more"`
+	NextPage string          `json:"next_page"`
+}
+
+// ----------------- RESPONSES API ------ Reference for Responses Request Body
+//   - OpenAI Docs: https://platform.openai.com/docs/api-reference/responses/create
+type OAResponseReq struct {
+	Model              string                   `json:"model"`                          // required, default model from client config used if empty
+	Input              interface{}              `json:"input"`                          // required, string or message array ([]OAMessageReq)
+	Instructions       string                   `json:"instructions,omitempty"`         // system (developer) message for the model
+	Tools              []map[string]interface{} `json:"tools,omitempty"`                // built-in tools like {"type": "web_search_preview"} or function tools
+	ToolChoice         interface{}              `json:"tool_choice,omitempty"`          // "none", "auto", "required", or object to force a tool
+	PreviousResponseID string                   `json:"previous_response_id,omitempty"` // for stateful conversation, id from previous response
+	MaxOutputTokens    int                      `json:"max_output_tokens,omitempty"`
+	Temperature        *float64                 `json:"temperature,omitempty"` // 0 to 2
+	Store              *bool                    `json:"store,omitempty"`       // default true on OpenAI, needed for previous_response_id
+	Metadata           map[string]string        `json:"metadata,omitempty"`
+	Reasoning          *OAReasoningConfig       `json:"reasoning,omitempty"` // only for reasoning models (o-series)
+}
+
+// reasoning setup for reasoning models (o3, o4-mini, ...)
+//   - OpenAI Docs: https://platform.openai.com/docs/guides/reasoning
+type OAReasoningConfig struct {
+	Effort  string `json:"effort,omitempty"`  // minimal, low, medium (default), or high
+	Summary string `json:"summary,omitempty"` // auto, concise, or detailed, the summary returned on the reasoning output item
+}
+
+// response from Responses API
+type OAResponseResp struct {
+	ID                 string                 `json:"id"`
+	Object             string                 `json:"object"` // "response"
+	CreatedAt          int64                  `json:"created_at"`
+	Status             string                 `json:"status"` // completed, failed, in_progress, or incomplete
+	Model              string                 `json:"model"`
+	Output             []OAResponseOutputItem `json:"output"`
+	PreviousResponseID *string                `json:"previous_response_id"` // could be null, so pointer
+	Usage              OAResponseUsage        `json:"usage"`
+	Error              *OAResponseError       `json:"error"` // filled when status is failed
+	IncompleteDetails  *struct {
+		Reason string `json:"reason"` // e.g. "max_output_tokens"
+	} `json:"incomplete_details"`
+}
+
+// output item, the field filled based on the type ("message", "function_call", "web_search_call", ...)
+type OAResponseOutputItem struct {
+	Type    string                    `json:"type"`
+	ID      string                    `json:"id"`
+	Status  string                    `json:"status,omitempty"`
+	Role    string                    `json:"role,omitempty"`    // message type
+	Content []OAResponseOutputContent `json:"content,omitempty"` // message type
+	// function_call type
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	CallID    string `json:"call_id,omitempty"`
+	// reasoning type, only filled if reasoning summary is requested
+	Summary []OAResponseReasoningSummary `json:"summary,omitempty"`
+}
+
+type OAResponseReasoningSummary struct {
+	Type string `json:"type"` // "summary_text"
+	Text string `json:"text"`
+}
+
+type OAResponseOutputContent struct {
+	Type    string `json:"type"` // "output_text" or "refusal"
+	Text    string `json:"text,omitempty"`
+	Refusal string `json:"refusal,omitempty"`
+}
+
+type OAResponseUsage struct {
+	InputTokens        int `json:"input_tokens"`
+	OutputTokens       int `json:"output_tokens"`
+	TotalTokens        int `json:"total_tokens"`
+	InputTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"input_tokens_details"`
+	OutputTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"output_tokens_details"`
+}
+
+type OAResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ReasoningSummary return the reasoning summary text from reasoning output items, the summary parts are separated by blank line.
+// the summary is only returned if requested with Reasoning.Summary on the request
+func (r *OAResponseResp) ReasoningSummary() string {
+	var summaries []string
+	for _, item := range r.Output {
+		if item.Type != "reasoning" {
+			continue
+		}
+
+		for _, summary := range item.Summary {
+			if summary.Type == "summary_text" {
+				summaries = append(summaries, summary.Text)
+			}
+		}
+	}
+
+	return strings.Join(summaries, "\n\n")
+}
+
+// ResolvedModel return the concrete model that served the response, for alias it is the versioned model
+func (r *OAResponseResp) ResolvedModel() string {
+	if r == nil {
+		return ""
+	}
+
+	return r.Model
+}
+
+// OutputText return all output text from message output items joined together, same as output_text on the official SDK
+func (r *OAResponseResp) OutputText() string {
+	var text string
+	for _, item := range r.Output {
+		if item.Type != "message" {
+			continue
+		}
+
+		for _, content := range item.Content {
+			if content.Type == "output_text" {
+				text += content.Text
+			}
+		}
+	}
+
+	return text
+}
+
+// ----------------- MODERATIONS ------ Reference for Moderation Request Body
+//   - OpenAI Docs: https://platform.openai.com/docs/api-reference/moderations/create
+type OAModerationReq struct {
+	Input interface{} `json:"input"`           // required, string or []string
+	Model string      `json:"model,omitempty"` // "omni-moderation-latest" used if empty
+}
+
+type OAModerationResp struct {
+	ID      string               `json:"id"`
+	Model   string               `json:"model"`
+	Results []OAModerationResult `json:"results"` // one result for each input, same order as the input
+}
+
+type OAModerationResult struct {
+	Flagged bool `json:"flagged"`
+	// category name (e.g. "harassment", "self-harm/intent", "violence/graphic") to flagged status
+	Categories map[string]bool `json:"categories"`
+	// category name to confidence score between 0 and 1
+	CategoryScores map[string]float64 `json:"category_scores"`
+	// category name to the input types ("text", "image") that flagged the category, only on omni moderation models
+	CategoryAppliedInputTypes map[string][]string `json:"category_applied_input_types,omitempty"`
+}
+
+// Flagged return true if any input of the request is flagged
+func (r *OAModerationResp) Flagged() bool {
+	for _, result := range r.Results {
+		if result.Flagged {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FlaggedCategories return the flagged category names of the result, sorted by name
+func (r OAModerationResult) FlaggedCategories() []string {
+	var categories []string
+	for category, flagged := range r.Categories {
+		if flagged {
+			categories = append(categories, category)
+		}
+	}
+	sort.Strings(categories)
+
+	return categories
+}
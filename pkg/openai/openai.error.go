@@ -0,0 +1,165 @@
+package openai
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// OAAPIError is the error returned when OpenAI API response with non 200 status code.
+// the error body from OpenAI is parsed so the caller can check the status and error code, example:
+//
+//	var apiErr *OAAPIError
+//	if errors.As(err, &apiErr) && apiErr.Code == "content_policy_violation" {
+//	    // prompt rejected by safety system
+//	}
+type OAAPIError struct {
+	StatusCode int    // http status code from response
+	Type       string // error type, e.g. "invalid_request_error"
+	Code       string // error code, e.g. "content_policy_violation", can be empty
+	Message    string // error message from OpenAI
+	Param      string // request field that caused the error, e.g. "messages[0].content", can be empty
+	// time to wait before retry from Retry-After header, only filled for 429 (rate limit) and 503 (overloaded) response if the header exist
+	RetryAfter time.Duration
+	// raw response body, kept as is for logging or body that is not OpenAI error structure (e.g. proxy HTML error page)
+	Body []byte
+	// number of attempts sent before this error, more than 1 when the request is retried with WithRetry
+	Attempts int
+}
+
+func (e *OAAPIError) Error() string {
+	msg := "OpenAI API response error: " + strconv.Itoa(e.StatusCode) + " " + http.StatusText(e.StatusCode)
+
+	if e.Message != "" {
+		msg += " with message: " + e.Message
+	}
+
+	if e.Type != "" {
+		msg += " type: " + e.Type
+	}
+
+	if e.Code != "" {
+		msg += " code: " + e.Code
+	}
+
+	if e.Param != "" {
+		msg += " param: " + e.Param
+	}
+
+	if e.Attempts > 1 {
+		msg += " after " + strconv.Itoa(e.Attempts) + " attempts"
+	}
+
+	return msg
+}
+
+// IsContentPolicyViolation check if the request is rejected by OpenAI safety system (image generation prompt rejection)
+func (e *OAAPIError) IsContentPolicyViolation() bool {
+	return e.Code == "content_policy_violation"
+}
+
+// IsContextLengthExceeded check if the request is rejected because the messages and the token limit is longer than the model context window
+func (e *OAAPIError) IsContextLengthExceeded() bool {
+	return e.Code == "context_length_exceeded"
+}
+
+// IsRateLimit check if the request is rejected by the rate limit or quota (429), wait for RetryAfter before sending again
+func (e *OAAPIError) IsRateLimit() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// OAIsRateLimit check if the error from the client methods is OpenAI rate limit error (429), the error can be wrapped
+//
+//	if openai.OAIsRateLimit(err) {
+//	    // slow down the requests
+//	}
+func OAIsRateLimit(err error) bool {
+	var apiErr *OAAPIError
+	return errors.As(err, &apiErr) && apiErr.IsRateLimit()
+}
+
+// IsRetriable check if the same request may succeed when sent again, true for rate limit (429) and server side error (500, 502, 503, 504)
+func (e *OAAPIError) IsRetriable() bool {
+	return oaIsRetriableStatus(e.StatusCode)
+}
+
+// oaIsRetriableStatus check if the response status is rate limit (429) or server side error (500, 502, 503, 504)
+func oaIsRetriableStatus(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+
+	return false
+}
+
+// oaNewAPIError create OAAPIError from non 200 response, the response body is read to get the OpenAI error message.
+// if the body is not OpenAI error structure, the error will only contain the status code
+// now is the client clock time used to get the wait duration of HTTP date Retry-After
+func oaNewAPIError(resp *http.Response, now time.Time) *OAAPIError {
+	apiErr := &OAAPIError{
+		StatusCode: resp.StatusCode,
+		Attempts:   1,
+	}
+
+	if resp.Request != nil {
+		if attempts, ok := resp.Request.Context().Value(oaAttemptsContextKey{}).(int); ok {
+			apiErr.Attempts = attempts
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		apiErr.RetryAfter = oaParseRetryAfter(resp.Header, now)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return apiErr
+	}
+	apiErr.Body = body
+
+	var errOpenAI OARespError
+	if err := json.Unmarshal(body, &errOpenAI); err != nil {
+		return apiErr
+	}
+
+	apiErr.Type = errOpenAI.Error.Type
+	apiErr.Code = errOpenAI.Error.Code
+	apiErr.Message = errOpenAI.Error.Message
+	apiErr.Param = errOpenAI.Error.Param
+
+	return apiErr
+}
+
+// oaParseRetryAfter parse the wait time from retry-after-ms (OpenAI) or Retry-After header, Retry-After can be in seconds or HTTP date.
+// return 0 if the header not exist or invalid
+func oaParseRetryAfter(header http.Header, now time.Time) time.Duration {
+	if retryAfterMs := header.Get("retry-after-ms"); retryAfterMs != "" {
+		if ms, err := strconv.ParseFloat(retryAfterMs, 64); err == nil && ms > 0 {
+			return time.Duration(ms * float64(time.Millisecond))
+		}
+	}
+
+	retryAfter := header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(retryAfter, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		if wait := date.Sub(now); wait > 0 {
+			return wait
+		}
+	}
+
+	return 0
+}
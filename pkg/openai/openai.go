@@ -2,12 +2,29 @@ package openai
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"io"
+	"math"
+	"math/rand"
+	"mime"
+	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
+	"unicode"
 )
 
 const (
@@ -15,8 +32,19 @@ const (
 	OAUrlTextCompletions       = OAUrlBase + "/chat/completions"
 	OAUrlImageGenerationsDallE = OAUrlBase + "/images/generations"
 	OAUrlTextToSpeech          = OAUrlBase + "/audio/speech"
+	OAUrlAudioTranscriptions   = OAUrlBase + "/audio/transcriptions"
+	OAUrlUsageCompletions      = OAUrlBase + "/organization/usage/completions"
+	OAUrlResponses             = OAUrlBase + "/responses"
+	OAUrlModerations           = OAUrlBase + "/moderations"
 )
 
+// OpenAI is the OpenAI API client created with New.
+//
+// The client is safe for concurrent use by multiple goroutines: the config is only set by the options on New and is read only after it,
+// and the functions never modify the caller request struct (request adjustment like default model, token limit field, system prompt,
+// and model mapping is done on a copy). Share one client on the application instead of creating one per request so the http connections are reused.
+// Callback and hook set by the options (WithAuditHook, WithUsageCallback, WithModelMapper) can be called from many goroutines at the same time,
+// so they must be safe for concurrent use too.
 type OpenAI interface {
 
 	// OpenAISendMessage sends a message to OpenAI's API and handles the request and response format.
@@ -32,6 +60,9 @@ type OpenAI interface {
 	//   - format_response: A map containing the JSON schema for formatting the response (can be created using OACreateResponseFormat).
 	//   - with_custom_reqbody: A boolean indicating whether a custom request body (`req_body_custom`) should be used.
 	//   - req_body_custom: A pointer to an OAReqBodyMessageCompletion struct. This is used if `with_custom_reqbody` is true.
+	//   - params: Optional. Sampling params for this call only (Temperature, TopP, MaxTokens, MaxCompletionTokens, N, Stop, PresencePenalty, Seed),
+	//     so generation can be tuned without a custom request body. Field already set on the custom body is kept, and field not set here
+	//     use the `WithSamplingParams` client default. With more than one params, the later one wins.
 	//
 	// Returns:
 	//   - A pointer to an OAChatCompletionResp struct containing the API response.
@@ -54,13 +85,109 @@ type OpenAI interface {
 	//	}
 	//	fmt.Printf("API response: %+v\n", response)
 	//
+	//	// more deterministic answer for this call only
+	//	temperature := 0.2
+	//	response, err = openaiAPIInstance.OpenAISendMessage(&content, false, nil, false, nil, OASamplingParams{Temperature: &temperature, MaxTokens: 200})
+	//
 	// Notes:
 	//   - The function checks for invalid states, such as missing content or custom request bodies when required.
+	//   - Messages where all content is empty, or a user message with empty / whitespace only content, are rejected locally. Empty assistant message is allowed.
+	//   - N: with `N` > 1 on the custom body, all choices share one usage object on the response (completion tokens is the sum of all choices),
+	//     use `response.CompletionTokensPerChoice()` for the per choice estimate.
+	//   - Using `with_format_response` together with a custom body that already has `ResponseFormat` returns an error instead of overwriting the custom format.
 	//   - The request is sent as a POST request with a JSON payload, and the response is decoded into the OAChatCompletionResp struct.
+	//   - Sampling params range: Temperature 0 to 2, TopP 0 to 1, PresencePenalty -2 to 2, N 1 to 128, and max 4 Stop sequences,
+	//     out of range value is rejected before sending.
+	//   - Token limit: reasoning and newer models (o1, o3, o4, gpt-5) only accept `max_completion_tokens` while older models only accept `max_tokens`.
+	//     Set the limit once (with `WithMaxTokens` for the default body, either `MaxTokens` / `MaxCompletionTokens` on the custom body or params)
+	//     and the function will send it on the field name the model accepts. The custom request body passed by the caller is not modified.
+	//   - Model fallback: with `WithModelFallback`, a retriable error (429 or 5xx) on the request model sends the same request with the next model in the chain.
+	//     The model that served the request is on `response.Model`, if all models fail the error of the last model is returned.
+	//   - Usage callback: with `WithUsageCallback`, the callback is called with the served model and usage after the response is decoded, never on error.
+	//   - System prompt: with `WithSystemPrompt`, the prompt is added as the first message unless the messages already have a system or developer message.
 	//
 	// References:
 	// - Official OpenAI API documentation: https://platform.openai.com/docs/api-reference/chat/create
-	OpenAISendMessage(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion) (*OAChatCompletionResp, error)
+	OpenAISendMessage(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAChatCompletionResp, error)
+
+	// OpenAISendMessageContext is OpenAISendMessage with a context, the request is created with the context so it is cancelled when the context is done.
+	// Use it for user initiated cancellation or a deadline tighter than the http client timeout, the WithModelFallback chain also stop when ctx is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAISendMessageContext(ctx context.Context, content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAChatCompletionResp, error)
+
+	// OpenAISendMessageStream sends a chat completion request with streaming and returns the response as a channel of delta chunks.
+	//
+	// The parameters and request body are the same as OpenAISendMessage, with "stream": true set on the body. The response is read
+	// from the server-sent events (text/event-stream) on a goroutine and each event is sent to the channel as an `OAChatCompletionChunk`,
+	// so a chat UI can render the content token by token.
+	//
+	// Parameters:
+	//   - ctx: Context for the stream. Cancel it when you stop reading the channel before the end, the response body is closed right away,
+	//     the reader goroutine stops, and the channel is closed without an error chunk.
+	//   - content, with_format_response, format_response, with_custom_reqbody, req_body_custom, params: Same as OpenAISendMessage.
+	//
+	// Returns:
+	//   - (<-chan OAChatCompletionChunk, error): The chunk channel, closed after the "data: [DONE]" event, an error, or ctx done.
+	//     The error is returned directly (not on the channel) when the parameters are invalid or OpenAI response with non 200 status (as `*OAAPIError`).
+	//     Error after the stream started (transport error, invalid event, or error event from OpenAI) is sent as the last chunk with `Err` set.
+	//
+	// Example usage:
+	//
+	//	ctx, cancel := context.WithCancel(context.Background())
+	//	defer cancel()
+	//
+	//	stream, err := client.OpenAISendMessageStream(ctx, &content, false, nil, false, nil)
+	//	if err != nil {
+	//	    log.Fatalf("Failed to start stream: %v", err)
+	//	}
+	//
+	//	for chunk := range stream {
+	//	    if chunk.Err != nil {
+	//	        log.Fatalf("Stream failed: %v", chunk.Err)
+	//	    }
+	//	    fmt.Print(chunk.Content())
+	//	}
+	//
+	// Notes:
+	//   - The WithModelFallback chain is not used for streaming, the stream is started with the request model only.
+	//   - Set `StreamOptions: &OAStreamOptions{IncludeUsage: true}` on the custom body to get the usage on the last chunk,
+	//     the WithUsageCallback callback is called with it.
+	//   - With Tools, the calls are streamed as fragments on `Delta.ToolCalls` (finish reason "tool_calls"), use OACollectStream
+	//     to join them into complete `Message.ToolCalls`.
+	//
+	// References:
+	//   - Streaming: https://platform.openai.com/docs/api-reference/chat-streaming
+	OpenAISendMessageStream(ctx context.Context, content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (<-chan OAChatCompletionChunk, error)
+
+	// OpenAISendMessageStreamTo sends a chat completion request with streaming and writes the content of each delta to w as it arrives.
+	//
+	// This is the simple version of OpenAISendMessageStream for CLI tools or http handler, the delta text is piped to stdout or
+	// the response writer without reading the channel. The usage is requested on the stream (include_usage) and returned at the end.
+	//
+	// Parameters:
+	//   - ctx: Context for the stream, cancel it to stop the stream.
+	//   - w: The writer for the content, e.g. os.Stdout or http.ResponseWriter (flush it on the writer if needed). This is required.
+	//   - content, with_format_response, format_response, with_custom_reqbody, req_body_custom, params: Same as OpenAISendMessage.
+	//
+	// Returns:
+	//   - (*OAUsage, error): The token usage of the whole request, nil if the server does not send it.
+	//     The error is the same as OpenAISendMessageStream (invalid parameters, `*OAAPIError`, or error after the stream started),
+	//     a write error on w stops the stream and is returned. The content written before the error is kept on w.
+	//     Request with Tools is rejected because the tool calls can not be written as text, use OpenAISendMessageStream with OACollectStream.
+	//
+	// Example usage:
+	//
+	//	usage, err := client.OpenAISendMessageStreamTo(ctx, os.Stdout, &content, false, nil, false, nil)
+	//	if err != nil {
+	//	    log.Fatalf("Stream failed: %v", err)
+	//	}
+	//	if usage != nil {
+	//	    fmt.Printf("\ntotal tokens: %d\n", usage.TotalTokens)
+	//	}
+	//
+	// References:
+	//   - Streaming: https://platform.openai.com/docs/api-reference/chat-streaming
+	OpenAISendMessageStreamTo(ctx context.Context, w io.Writer, content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAUsage, error)
 
 	// OpenAIGetFirstContentDataResp retrieves the first content data from an OpenAI API response.
 	//
@@ -71,6 +198,7 @@ type OpenAI interface {
 	//   - content: A pointer to a slice of OAMessageReq, which represents the request message content to be sent to OpenAI.
 	//   - with_format_response: A boolean indicating whether the response should be formatted.
 	//   - format_response: A map that contains additional formatting options for the response. if you need to use the format_response that supported by OpenAI API. Official Docs and structure about structured response OpenAPI schema in: https://platform.openai.com/docs/guides/structured-outputs/examples
+	//   - with_custom_reqbody, req_body_custom, params: Same as OpenAISendMessage.
 	//
 	// Returns:
 	//   - A pointer to an OAMessage struct that contains the first content data from the response.
@@ -91,7 +219,11 @@ type OpenAI interface {
 	//
 	// References:
 	// - Official OpenAI API documentation: https://platform.openai.com/docs/api-reference/chat/create
-	OpenAIGetFirstContentDataResp(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion) (*OAMessage, error)
+	OpenAIGetFirstContentDataResp(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAMessage, error)
+
+	// OpenAIGetFirstContentDataRespContext is OpenAIGetFirstContentDataResp with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAIGetFirstContentDataRespContext(ctx context.Context, content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAMessage, error)
 
 	// OpenAICreateImageDallE generates images based on a text prompt using either the DALL-E 2 or DALL-E 3 model.
 	//
@@ -145,7 +277,7 @@ type OpenAI interface {
 	//	    log.Fatalf("Image generation failed: %v", err)
 	//	}
 	//
-	// Function Logic:
+	// Function Logic (steps 1-5 are done by `ValidateImageRequest`):
 	//  1. **Model Validation**: Ensures `Model` is either "dall-e-2" or "dall-e-3". If not, returns an error.
 	//  2. **N Validation**: If `N` is provided, checks if it falls between 1 and 10 (inclusive). If out of range, returns an error.
 	//  3. **Quality Validation**:
@@ -158,9 +290,10 @@ type OpenAI interface {
 	//     - If `ResponseFormat` is specified, it must be either "url" or "b64_json". Returns an error for other values.
 	//  6. **API Key Check**: Confirms that the API key is set; returns an error if it's empty.
 	//  7. **JSON Marshalling**: Serializes `req_body` to JSON format for the request body.
-	//  8. **Request Creation and Headers**: Sets up an HTTP POST request with necessary headers (`Content-Type` and `Authorization`).
+	//  8. **Request Creation and Headers**: Sets up an HTTP POST request with necessary headers (`Content-Type`, `Authorization`, and `OpenAI-Organization` / `OpenAI-Project` when provided on New).
 	//  9. **Response Handling**:
-	//     - If the HTTP response status is not 200 OK, reads and closes the response body, then returns an error.
+	//     - If the HTTP response status is not 200 OK, the OpenAI error body is parsed and returned as `*OAAPIError` (status code, type, code, and message),
+	//       so a rejected prompt can be detected with `errors.As` and `OAAPIError.IsContentPolicyViolation()` (code "content_policy_violation").
 	//     - On successful response, decodes JSON data into `OAImageGeneratorDallEResp` struct and returns it.
 	//
 	// Considerations:
@@ -172,6 +305,10 @@ type OpenAI interface {
 	//   - OpenAI DALL E Image Generation API: https://platform.openai.com/docs/api-reference/images/create
 	OpenAICreateImageDallE(req_body *OAReqImageGeneratorDallE) (*OAImageGeneratorDallEResp, error)
 
+	// OpenAICreateImageDallEContext is OpenAICreateImageDallE with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAICreateImageDallEContext(ctx context.Context, req_body *OAReqImageGeneratorDallE) (*OAImageGeneratorDallEResp, error)
+
 	// OpenAITextToSpeech converts a text input into a speech audio file using OpenAI's TTS models.
 	// This function validates the input parameters, prepares the request, sends it to the OpenAI API,
 	// and returns the audio response encoded in base64 format.
@@ -214,13 +351,426 @@ type OpenAI interface {
 	// References:
 	//   - TTS OpenAI: https://platform.openai.com/docs/api-reference/audio/createSpeech
 	OpenAITextToSpeech(req_body *OAReqTextToSpeech) (*OATextToSpeechResp, error)
+
+	// OpenAITextToSpeechContext is OpenAITextToSpeech with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAITextToSpeechContext(ctx context.Context, req_body *OAReqTextToSpeech) (*OATextToSpeechResp, error)
+
+	// OpenAITextToSpeechLong converts text longer than the TTS input limit (4096 characters) to speech and writes the audio to `w`.
+	//
+	// The input is split at sentence boundaries into segments under the limit, each segment is sent as one TTS request
+	// with the same model, voice, format, and speed, and the audio of all segments is written to `w` in order.
+	//
+	// Parameters:
+	//   - req_body: The TTS request, same as OpenAITextToSpeech but Input can be longer than 4096 characters.
+	//     ResponseFormat must be mp3 (default), aac, or pcm because the audio of these formats can be concatenated directly.
+	//   - w: The writer for the concatenated audio, e.g. a file.
+	//
+	// Returns:
+	//   - error: An error if the request is invalid, a segment request fails, or writing to `w` fails.
+	//     When a segment fails, the audio of the previous segments is already written to `w`.
+	//
+	// Example usage:
+	//
+	//	file, _ := os.Create("article.mp3")
+	//	defer file.Close()
+	//
+	//	err := client.OpenAITextToSpeechLong(&OAReqTextToSpeech{
+	//	    Model: "tts-1",
+	//	    Input: articleText,
+	//	    Voice: "alloy",
+	//	}, file)
+	//	if err != nil {
+	//	    log.Fatalf("Failed to create speech: %v", err)
+	//	}
+	//
+	// References:
+	//   - TTS OpenAI: https://platform.openai.com/docs/api-reference/audio/createSpeech
+	OpenAITextToSpeechLong(req_body *OAReqTextToSpeech, w io.Writer) error
+
+	// OpenAITextToSpeechLongContext is OpenAITextToSpeechLong with a context, the request is created with the context so it is cancelled when the context is done.
+	// When ctx is done the remaining segments are not requested, the audio of the segments finished before is already written to `w`.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAITextToSpeechLongContext(ctx context.Context, req_body *OAReqTextToSpeech, w io.Writer) error
+
+	// OpenAIGetUsage retrieves the organization completions usage between start and end time from the OpenAI usage endpoint.
+	//
+	// The usage endpoint is an organization admin endpoint, so it need an **admin key** (created on the organization settings admin keys page)
+	// and not the normal project API key used for the other functions. Set the admin key on the client with `WithAdminKey`.
+	// All pages returned by the endpoint are fetched and merged, so the returned data contains all usage buckets (daily bucket) in the time range.
+	// The request is sent with the admin key as the bearer token, with the organization and project headers like other calls,
+	// and to the API root of `WithBaseUrl` when it is set.
+	//
+	// Parameters:
+	//   - ctx: Context for the request, can be used to cancel the request or set a deadline.
+	//   - start: Start time (inclusive) of the usage data. This is required.
+	//   - end: End time (exclusive) of the usage data. Can be zero time to get usage until now, otherwise must be after start.
+	//
+	// Returns:
+	//   - (*OAUsageResp, error): On success, returns a pointer to an `OAUsageResp` struct containing all usage buckets,
+	//     each bucket contains the aggregated input/output tokens and number of model requests. Returns an error if the admin key is missing,
+	//     the time range is invalid, or the request fails.
+	//
+	// Example usage:
+	//
+	//	client, _ := New("your-api-key", "", "", WithAdminKey("your-admin-key"))
+	//
+	//	usage, err := client.OpenAIGetUsage(context.Background(), time.Now().AddDate(0, 0, -7), time.Now())
+	//	if err != nil {
+	//	    log.Fatalf("Failed to get usage: %v", err)
+	//	}
+	//
+	//	for _, bucket := range usage.Data {
+	//	    for _, result := range bucket.Results {
+	//	        fmt.Println(bucket.StartTime, result.InputTokens, result.OutputTokens)
+	//	    }
+	//	}
+	//
+	// References:
+	//   - Usage API: https://platform.openai.com/docs/api-reference/usage/completions
+	//   - Admin API Keys: https://platform.openai.com/docs/api-reference/admin-api-keys
+	OpenAIGetUsage(ctx context.Context, start time.Time, end time.Time) (*OAUsageResp, error)
+
+	// OpenAISpeechToTextRaw sends a pre-built multipart form body to the OpenAI transcription endpoint and returns the raw response body.
+	//
+	// This is a low level function for advanced usage, the multipart form is built by the caller so any field supported by the API
+	// can be sent (file, model, language, prompt, response_format, timestamp_granularities[], ...) even if it is not modeled by this package.
+	// The request uses the same setup as the other functions (http client, API key, organization and project id headers).
+	//
+	// Parameters:
+	//   - body: The multipart form body. This is required.
+	//   - contentType: The multipart content type including the boundary, usually from `multipart.Writer.FormDataContentType()`.
+	//
+	// Returns:
+	//   - ([]byte, error): The raw response body, JSON or plain text based on the `response_format` field of the form.
+	//     An error is returned if the parameters are invalid, the request fails, or OpenAI response with non 200 status (as `*OAAPIError`).
+	//
+	// Example usage:
+	//
+	//	var form bytes.Buffer
+	//	writer := multipart.NewWriter(&form)
+	//	writer.WriteField("model", "whisper-1")
+	//	part, _ := writer.CreateFormFile("file", "audio.mp3")
+	//	part.Write(audioBytes)
+	//	writer.Close()
+	//
+	//	raw, err := client.OpenAISpeechToTextRaw(&form, writer.FormDataContentType())
+	//	if err != nil {
+	//	    log.Fatalf("Failed to transcribe audio: %v", err)
+	//	}
+	//	fmt.Println(string(raw))
+	//
+	// References:
+	//   - Create transcription: https://platform.openai.com/docs/api-reference/audio/createTranscription
+	OpenAISpeechToTextRaw(body io.Reader, contentType string) ([]byte, error)
+
+	// OpenAISpeechToTextRawContext is OpenAISpeechToTextRaw with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAISpeechToTextRawContext(ctx context.Context, body io.Reader, contentType string) ([]byte, error)
+
+	// OpenAIDoJSON sends a JSON request to any OpenAI (or OpenAI compatible) endpoint and decodes the JSON response into `out`.
+	//
+	// This is a low level function for endpoints that are not modeled yet by this package, like new OpenAI endpoints or proprietary
+	// endpoints added by OpenAI compatible servers. The request uses the same setup as the other functions (http client, API key,
+	// organization and project id headers).
+	//
+	// Parameters:
+	//   - ctx: Context for the request, can be used to cancel the request or set a deadline.
+	//   - method: HTTP method, e.g. http.MethodGet or http.MethodPost.
+	//   - path: Endpoint path relative to the API root, e.g. "/models". The API root is the configured base url without
+	//     the "/chat/completions" suffix (default "https://api.openai.com/v1"). A full url (starting with http:// or https://) is used as it is.
+	//   - body: Request body that will be marshaled to JSON, can be nil for request without body.
+	//   - out: Pointer to the value the JSON response is decoded into, can be nil if the response body is not needed.
+	//
+	// Returns:
+	//   - An error if the request fails. Non 2xx response is returned as `*OAAPIError`.
+	//
+	// Example usage:
+	//
+	//	var models map[string]interface{}
+	//	if err := client.OpenAIDoJSON(context.Background(), http.MethodGet, "/models", nil, &models); err != nil {
+	//	    log.Fatalf("Failed to list models: %v", err)
+	//	}
+	//	fmt.Println(models)
+	OpenAIDoJSON(ctx context.Context, method string, path string, body interface{}, out interface{}) error
+
+	// OpenAICreateResponse sends a request to the OpenAI Responses API and returns the full response.
+	//
+	// The Responses API is the newer interface recommended by OpenAI, it supports built-in tools (web search, file search, ...) and
+	// stateful conversation using `PreviousResponseID` so the conversation history does not need to be sent again.
+	//
+	// Parameters:
+	//   - req_body (*OAResponseReq): A pointer to the request struct.
+	//   - Model (string): The model used, if empty the default model from the client config (`WithModel`) is used.
+	//   - Input (interface{}): Required. A string prompt or a message array (`[]OAMessageReq`).
+	//   - Instructions (string): Optional. System (developer) message inserted into the model context.
+	//   - Tools ([]map[string]interface{}): Optional. Tools the model can use, e.g. `{"type": "web_search_preview"}`.
+	//   - PreviousResponseID (string): Optional. The id of the previous response to continue the conversation.
+	//   - Reasoning (*OAReasoningConfig): Optional. Only for reasoning models (o3, o4-mini, ...), set `Effort` (minimal, low, medium, or high)
+	//     and `Summary` (auto, concise, or detailed). The summary can be read with `OAResponseResp.ReasoningSummary()`.
+	//
+	// Returns:
+	//   - (*OAResponseResp, error): On success, returns a pointer to an `OAResponseResp` struct containing the output items and usage.
+	//     Returns an error if the input is missing or the request fails (non 200 response is returned as `*OAAPIError`).
+	//
+	// Example usage:
+	//
+	//	resp, err := client.OpenAICreateResponse(&OAResponseReq{
+	//	    Model:        "gpt-4o-mini",
+	//	    Instructions: "You are a helpful assistant.",
+	//	    Input:        "Tell me a joke",
+	//	})
+	//	if err != nil {
+	//	    log.Fatalf("Failed to create response: %v", err)
+	//	}
+	//	fmt.Println(resp.OutputText())
+	//
+	// References:
+	//   - Responses API: https://platform.openai.com/docs/api-reference/responses/create
+	OpenAICreateResponse(req_body *OAResponseReq) (*OAResponseResp, error)
+
+	// OpenAICreateResponseContext is OpenAICreateResponse with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAICreateResponseContext(ctx context.Context, req_body *OAResponseReq) (*OAResponseResp, error)
+
+	// OpenAIGetResponseOutputText sends a request to the OpenAI Responses API and returns only the output text.
+	//
+	// This function is a simple version of `OpenAICreateResponse` if you only need the model answer, the text from all message
+	// output items is joined together (same as `output_text` on the official SDK). An error is returned if the response status is failed.
+	//
+	// Example usage:
+	//
+	//	text, err := client.OpenAIGetResponseOutputText(&OAResponseReq{Input: "Tell me a joke"})
+	//	if err != nil {
+	//	    log.Fatalf("Failed to create response: %v", err)
+	//	}
+	//	fmt.Println(text)
+	//
+	// References:
+	//   - Responses API: https://platform.openai.com/docs/api-reference/responses/create
+	OpenAIGetResponseOutputText(req_body *OAResponseReq) (string, error)
+
+	// OpenAIGetResponseOutputTextContext is OpenAIGetResponseOutputText with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAIGetResponseOutputTextContext(ctx context.Context, req_body *OAResponseReq) (string, error)
+
+	// OpenAIRetrieveCompletion retrieves a stored chat completion by id.
+	//
+	// Only chat completions created with `Store` set to true (pointer to true) on the request body can be retrieved, this is useful for auditing
+	// and compliance logging of the model interactions.
+	//
+	// Parameters:
+	//   - id: The chat completion id (the `ID` field from `OAChatCompletionResp`, e.g. "chatcmpl-abc123").
+	//
+	// Returns:
+	//   - (*OAChatCompletionResp, error): The stored chat completion, or an error if the id is empty or the request fails.
+	//
+	// Example usage:
+	//
+	//	completion, err := client.OpenAIRetrieveCompletion("chatcmpl-abc123")
+	//	if err != nil {
+	//	    log.Fatalf("Failed to retrieve completion: %v", err)
+	//	}
+	//	fmt.Println(completion.Choices[0].Message.Content)
+	//
+	// References:
+	//   - Get chat completion: https://platform.openai.com/docs/api-reference/chat/get
+	OpenAIRetrieveCompletion(id string) (*OAChatCompletionResp, error)
+
+	// OpenAIRetrieveCompletionContext is OpenAIRetrieveCompletion with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAIRetrieveCompletionContext(ctx context.Context, id string) (*OAChatCompletionResp, error)
+
+	// OpenAIListCompletions lists stored chat completions (created with `Store` set to true).
+	//
+	// Parameters:
+	//   - req_query (*OAListCompletionsReq): Optional (can be nil). Pagination and filter for the list: `After`, `Limit`, `Order`
+	//     ("asc" or "desc"), `Model`, and `Metadata` (filter by metadata key and value).
+	//
+	// Returns:
+	//   - (*OAChatCompletionListResp, error): One page of stored chat completions, use `LastID` as `After` to get the next page if `HasMore` is true.
+	//
+	// Example usage:
+	//
+	//	list, err := client.OpenAIListCompletions(&OAListCompletionsReq{Limit: 10, Metadata: map[string]string{"user": "123"}})
+	//	if err != nil {
+	//	    log.Fatalf("Failed to list completions: %v", err)
+	//	}
+	//	for _, completion := range list.Data {
+	//	    fmt.Println(completion.ID)
+	//	}
+	//
+	// References:
+	//   - List chat completions: https://platform.openai.com/docs/api-reference/chat/list
+	OpenAIListCompletions(req_query *OAListCompletionsReq) (*OAChatCompletionListResp, error)
+
+	// OpenAIListCompletionsContext is OpenAIListCompletions with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAIListCompletionsContext(ctx context.Context, req_query *OAListCompletionsReq) (*OAChatCompletionListResp, error)
+
+	// OpenAIResolveModel resolves a model alias (e.g. "gpt-4o") to the concrete dated model id (e.g. "gpt-4o-2024-08-06").
+	//
+	// The available models are listed from the models endpoint and the newest dated version of the alias is returned,
+	// if the alias has no dated version but exist on the list, the alias itself is returned.
+	// Use it at startup to log the concrete model for reproducibility.
+	//
+	// Parameters:
+	//   - ctx: Context for the request, can be used to cancel the request or set a deadline.
+	//   - alias: The model alias to resolve. This is required.
+	//
+	// Returns:
+	//   - (string, error): The concrete model id, or an error if the alias is not available for the API key or the request fails.
+	//
+	// Example usage:
+	//
+	//	model, err := client.OpenAIResolveModel(context.Background(), "gpt-4o")
+	//	if err != nil {
+	//	    log.Fatalf("Failed to resolve model: %v", err)
+	//	}
+	//	log.Printf("using model %s", model)
+	//
+	// Notes:
+	//   - OpenAI moves the alias to newer version over time, so the concrete id changes. Re-resolve periodically (e.g. on each deploy or daily)
+	//     instead of caching the result forever.
+	//   - The newest dated version on the list may be released before the alias is moved to it, the result is the best guess from the model list.
+	//
+	// References:
+	//   - List models: https://platform.openai.com/docs/api-reference/models/list
+	//   - Model snapshots: https://platform.openai.com/docs/models
+	OpenAIResolveModel(ctx context.Context, alias string) (string, error)
+
+	// OpenAIEffectiveTimeout reports the maximum wall-clock time one chat request (OpenAISendMessage) can take with the client setup.
+	//
+	// The time is calculated from the http client timeout (per attempt) multiplied by the number of attempts (request model plus
+	// the `WithModelFallback` models different from it, each sent 1 + `WithRetry` maxRetries times with the max backoff wait between them,
+	// and the whole chain twice with `WithContextTrimming`), and limited by the context deadline when the context has one.
+	// Retry-After wait from the server is not included.
+	//
+	// Parameters:
+	//   - ctx: The context that will be used for the request, can be context.Background() if the request has no deadline.
+	//   - model: The model of the request (custom body Model), empty string for the client default model.
+	//
+	// Returns:
+	//   - (time.Duration, bool): The maximum time and true if the time is bounded. false means the request has no time limit
+	//     (http client without Timeout and context without deadline), set one of them for production usage.
+	//     The time is 0 and true when the context deadline is already passed.
+	//
+	// Example usage:
+	//
+	//	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	//	defer cancel()
+	//
+	//	if maxTime, bounded := client.OpenAIEffectiveTimeout(ctx, ""); bounded {
+	//	    log.Printf("chat request can take up to %s", maxTime)
+	//	}
+	OpenAIEffectiveTimeout(ctx context.Context, model string) (time.Duration, bool)
+
+	// OpenAIVerifyImageURL checks an image URL is reachable and serves an image before it is used on a vision request.
+	//
+	// A dead or private image URL makes the vision request fail after it is sent (and billed), this opt-in check sends a HEAD request
+	// (GET when the server does not allow HEAD) to the URL with the client http setup and checks the response is 200 with an image content type.
+	// The API key and OpenAI headers are not sent to the image host.
+	//
+	// Parameters:
+	//   - ctx: Context for the request, can be used to cancel the request or set a deadline.
+	//   - imageUrl: The http or https image URL. This is required.
+	//
+	// Returns:
+	//   - error: nil if the URL is reachable and the content type is supported for vision (png, jpeg, gif, webp), otherwise an error with the reason.
+	//
+	// Example usage:
+	//
+	//	if err := client.OpenAIVerifyImageURL(context.Background(), imageUrl); err != nil {
+	//	    log.Printf("skip broken image: %v", err)
+	//	} else {
+	//	    content, err := OACreateOneContentVision("", true, imageUrl, "Describe this image")
+	//	    // ...
+	//	}
+	OpenAIVerifyImageURL(ctx context.Context, imageUrl string) error
+
+	// LastRequestBody returns a copy of the most recent request body sent by the client, for reproducing an issue or filing a bug report.
+	//
+	// The body is only captured when the client is created with `WithCaptureLastRequest(true)`, otherwise nil is returned.
+	// Request with streaming body (OpenAISpeechToTextRaw) is not captured.
+	//
+	// Notes:
+	//   - The body is captured as sent without redaction, it can contain sensitive user data and large content (base64 image or audio).
+	//   - The client is shared by goroutines, so on concurrent usage the last body can be from another call.
+	//
+	// Example usage:
+	//
+	//	client, _ := New("your-api-key", "", "", WithCaptureLastRequest(true))
+	//	if _, err := client.OpenAISendMessage(&content, false, nil, false, nil); err != nil {
+	//	    log.Printf("request failed: %v, body: %s", err, client.LastRequestBody())
+	//	}
+	LastRequestBody() []byte
+
+	// OpenAIModerate checks text with the OpenAI moderation model, use it to filter user input before it is sent to chat completions.
+	//
+	// Parameters:
+	//   - req_body (*OAModerationReq): A pointer to the request struct.
+	//   - Input (interface{}): Required. A text (string) or many texts ([]string), each text get one result on the same order.
+	//   - Model (string): Optional. The moderation model, "omni-moderation-latest" is used if empty.
+	//
+	// Returns:
+	//   - (*OAModerationResp, error): On success, returns a pointer to an `OAModerationResp` with one result for each input,
+	//     each result has `Flagged`, the `Categories` booleans, and the `CategoryScores`. `OAModerationResp.Flagged()` is true if any input is flagged.
+	//     Returns an error if no input is provided or the request fails (non 200 response is returned as `*OAAPIError`).
+	//
+	// Example usage:
+	//
+	//	moderation, err := client.OpenAIModerate(&OAModerationReq{Input: userMessage})
+	//	if err != nil {
+	//	    log.Fatalf("Failed to moderate input: %v", err)
+	//	}
+	//	if moderation.Flagged() {
+	//	    log.Printf("input rejected: %v", moderation.Results[0].FlaggedCategories())
+	//	    return
+	//	}
+	//
+	// References:
+	//   - Moderations API: https://platform.openai.com/docs/api-reference/moderations/create
+	OpenAIModerate(req_body *OAModerationReq) (*OAModerationResp, error)
+
+	// OpenAIModerateContext is OpenAIModerate with a context, the request is created with the context so it is cancelled when the context is done.
+	// A cancelled or expired context return an error wrapping context.Canceled or context.DeadlineExceeded (check with errors.Is).
+	OpenAIModerateContext(ctx context.Context, req_body *OAModerationReq) (*OAModerationResp, error)
 }
 
 // Config holds the configuration for OpenAI API client
 type Config struct {
-	httpClient    *http.Client
-	openAIBaseUrl string
-	openAIModel   string
+	httpClient      *http.Client
+	openAIBaseUrl   string
+	openAIModel     string
+	openAIMaxTokens int
+	openAIAdminKey  string
+	promptCacheKey  string
+	fallbackModels  []string
+	systemPrompt    string
+	usageCallback   func(model string, usage OAUsage)
+	modelMapper     func(model string) string
+	requestHook     func(start OARequestStart) func(end OARequestEnd)
+	captureRequest  bool
+	auditHook       func(method string, url string, header http.Header)
+	clock           oaClock
+	// max response body size in bytes, 0 is no limit
+	maxResponseBytes int64
+	// retry setup of retriable status, 0 maxRetries is no retry
+	maxRetries     int
+	retryBaseDelay time.Duration
+	retryIf        func(apiErr *OAAPIError) bool
+	// drop the oldest messages and send once more on context_length_exceeded error
+	trimOnContextLength bool
+	// send json_schema response format as json_object on model without structured outputs support
+	jsonModeFallback bool
+	// default sampling setup of chat request, field set on the custom request body is not overwritten
+	samplingParams OASamplingParams
+
+	// transport setup, applied on New after all options so it also apply to the http client from WithHTTPClient
+	forceHTTP1            bool
+	dialTimeout           time.Duration
+	responseHeaderTimeout time.Duration
+	disableKeepAlives     bool
 }
 
 // default configuration for OpenAI API client
@@ -232,7 +782,87 @@ func DefaultConfig() *Config {
 		// user base url for chat completions endpoint with using gpt-4o-mini model
 		openAIBaseUrl: OAUrlTextCompletions,
 		openAIModel:   "gpt-4o-mini",
+		clock:         oaRealClock{},
+	}
+}
+
+// oaClock is the time source of the client, all wait (Retry-After, retry backoff) use it so test can replace it without real sleep
+type oaClock interface {
+	Now() time.Time
+	// Sleep wait for the duration or until the context is done, return the context error if the wait is cancelled
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// oaRealClock is the default clock using the time package
+type oaRealClock struct{}
+
+func (oaRealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (oaRealClock) Sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// clock setup for test, not exported because the clock is only replaced to make retry and rate limit wait deterministic
+func withClock(clock oaClock) ClientOption {
+	return func(c *Config) {
+		c.clock = clock
+	}
+}
+
+// applyTransportOptions configure the http client transport based on the transport setup on config.
+// the http client is copied so the client passed from WithHTTPClient is not changed, error if the transport is a custom RoundTripper
+func (c *Config) applyTransportOptions() error {
+	if !c.forceHTTP1 && c.dialTimeout == 0 && c.responseHeaderTimeout == 0 && !c.disableKeepAlives {
+		return nil
+	}
+
+	var transport *http.Transport
+	switch t := c.httpClient.Transport.(type) {
+	case nil:
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	case *http.Transport:
+		transport = t.Clone()
+	default:
+		// custom RoundTripper can not be configured, fail instead of dropping the options silently
+		return errors.New("WithForceHTTP1, WithDialTimeout, WithResponseHeaderTimeout, and WithDisableKeepAlives need the http client Transport to be nil or *http.Transport, set them on the custom RoundTripper instead")
+	}
+
+	if c.forceHTTP1 {
+		// non nil empty TLSNextProto map disable HTTP/2 on the transport
+		transport.ForceAttemptHTTP2 = false
+		transport.TLSNextProto = map[string]func(authority string, c *tls.Conn) http.RoundTripper{}
+	}
+
+	if c.dialTimeout > 0 {
+		transport.DialContext = (&net.Dialer{
+			Timeout:   c.dialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext
+	}
+
+	if c.responseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = c.responseHeaderTimeout
+	}
+
+	if c.disableKeepAlives {
+		transport.DisableKeepAlives = true
 	}
+
+	httpClient := *c.httpClient
+	httpClient.Transport = transport
+	c.httpClient = &httpClient
+
+	return nil
 }
 
 // client implementation for OpenAI API interfaces
@@ -241,28 +871,311 @@ type openaiAPI struct {
 	openaiOrganization string
 	openaiProject      string
 	config             *Config
+
+	// last request body for WithCaptureLastRequest, guarded by the mutex because the client is used concurrently
+	lastRequestMu   sync.Mutex
+	lastRequestBody []byte
 }
 
-// client options for configuring the OpenAI API client
-type ClientOption func(*Config)
+// apiRoot get the API root url from the configured base url (chat completions endpoint), used for endpoint that not have own url constant
+func (c *openaiAPI) apiRoot() string {
+	return strings.TrimSuffix(strings.TrimSuffix(c.config.openAIBaseUrl, "/"), "/chat/completions")
+}
 
-// New creates and returns a new instance of the OpenAI API client.
-//
-// This function initializes the OpenAI client with the provided API key, organization, and project ID.
-// Additionally, it applies any optional configuration options passed via the variadic `opts` parameter.
-//
-// Parameters:
-//   - apiKey: The API key used to authenticate with OpenAI's API. This is required and cannot be empty.
-//   - openaiOrganization: (Optional) The organization ID associated with your OpenAI account. This can be omitted if not applicable.
-//   - openaiProject: (Optional) The project ID associated with your OpenAI account. This can be omitted if not applicable.
-//   - opts: A variadic parameter that accepts one or more `ClientOption` functions to customize the client's behavior.
-//
-// Returns:
-//   - An OpenAI client instance, or an error if the API key is missing or another issue occurs during initialization.
-//
-// Default Configuration Values:
-//   - httpClient: The HTTP client used for making requests. By default, the client has a
-//     timeout of 60 seconds (`http.Client{ Timeout: 60 * time.Second }`).
+// setRequestHeaders set the auth header and the optional organization and project header on request
+func (c *openaiAPI) setRequestHeaders(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+
+	// per call override from OAWithOrganizationProject, set on this request header only
+	organization, project := c.openaiOrganization, c.openaiProject
+	if override, ok := req.Context().Value(oaOrganizationProjectContextKey{}).(oaOrganizationProject); ok {
+		if override.organization != "" {
+			organization = override.organization
+		}
+		if override.project != "" {
+			project = override.project
+		}
+	}
+
+	if organization != "" {
+		req.Header.Set("OpenAI-Organization", organization)
+	}
+
+	if project != "" {
+		req.Header.Set("OpenAI-Project", project)
+	}
+}
+
+// sendRequest send the request with the configured http client, all request to OpenAI is sent through this function.
+// with WithRetry, retriable status (429 and 500, 502, 503, 504) is sent again after the backoff or Retry-After wait
+func (c *openaiAPI) sendRequest(req *http.Request) (*http.Response, error) {
+	if c.config.maxRetries <= 0 {
+		return c.doRequest(req)
+	}
+
+	// the body is sent again on each attempt, body without GetBody (e.g. multipart STT reader) is read to memory once so it can be rebuilt
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		bodyBytes, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read request body for retry: %w", err)
+		}
+
+		req.Body = io.NopCloser(bytes.NewReader(bodyBytes))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(bodyBytes)), nil
+		}
+	}
+
+	ctx := req.Context()
+	for attempt := 1; ; attempt++ {
+		attemptReq := req.WithContext(context.WithValue(ctx, oaAttemptsContextKey{}, attempt))
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("Failed to rebuild request body for retry: %w", err)
+			}
+			attemptReq.Body = body
+		}
+
+		resp, err := c.doRequest(attemptReq)
+		if err != nil || attempt > c.config.maxRetries || !c.retriable(resp) {
+			return resp, err
+		}
+
+		wait := oaParseRetryAfter(resp.Header, c.config.clock.Now())
+		if wait <= 0 {
+			wait = c.retryBackoff(attempt)
+		}
+
+		// drain so the connection can be reused by the next attempt
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+
+		if err := c.config.clock.Sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// retriable check if the response should be sent again, the retriable status is checked by default or the WithRetryIf predicate.
+// for the predicate, the error body is read to build OAAPIError and put back on the response so the caller can still read it
+func (c *openaiAPI) retriable(resp *http.Response) bool {
+	if c.config.retryIf == nil {
+		return oaIsRetriableStatus(resp.StatusCode)
+	}
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	errResp := *resp
+	errResp.Body = io.NopCloser(bytes.NewReader(body))
+
+	return c.config.retryIf(oaNewAPIError(&errResp, c.config.clock.Now()))
+}
+
+// retryBackoff return the wait before the next attempt, the jitter randomize the second half of the max delay
+// so many clients do not retry at the same time
+func (c *openaiAPI) retryBackoff(attempt int) time.Duration {
+	half := c.maxRetryDelay(attempt) / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// maxRetryDelay return the max backoff after the attempt, the base delay is doubled on each attempt (max oaMaxRetryBackoff)
+func (c *openaiAPI) maxRetryDelay(attempt int) time.Duration {
+	delay := c.config.retryBaseDelay
+	for i := 1; i < attempt && delay < oaMaxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > oaMaxRetryBackoff {
+		delay = oaMaxRetryBackoff
+	}
+
+	return delay
+}
+
+// doRequest send one attempt of the request with the audit hook, request hook, and response size limit
+func (c *openaiAPI) doRequest(req *http.Request) (*http.Response, error) {
+	if c.config.auditHook != nil {
+		c.config.auditHook(req.Method, req.URL.String(), oaRedactHeader(req.Header))
+	}
+
+	if c.config.captureRequest && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			captured, _ := io.ReadAll(body)
+			body.Close()
+
+			c.lastRequestMu.Lock()
+			c.lastRequestBody = captured
+			c.lastRequestMu.Unlock()
+		}
+	}
+
+	var onEnd func(end OARequestEnd)
+	if c.config.requestHook != nil {
+		model, _ := req.Context().Value(oaModelContextKey{}).(string)
+		onEnd = c.config.requestHook(OARequestStart{
+			Context: req.Context(),
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Model:   model,
+			Header:  req.Header,
+		})
+	}
+
+	startTime := c.config.clock.Now()
+	resp, err := c.config.httpClient.Do(req)
+
+	if onEnd != nil {
+		end := OARequestEnd{
+			Duration: c.config.clock.Now().Sub(startTime),
+			Err:      err,
+		}
+		if resp != nil {
+			end.StatusCode = resp.StatusCode
+			end.RequestID = resp.Header.Get("x-request-id")
+		}
+		onEnd(end)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// every body read (JSON decode, error body, and TTS audio) go through the limited body
+	if c.config.maxResponseBytes > 0 {
+		resp.Body = &oaLimitedBody{body: resp.Body, remaining: c.config.maxResponseBytes, limit: c.config.maxResponseBytes}
+	}
+
+	return resp, nil
+}
+
+// oaLimitedBody return error when the response body is larger than the limit, instead of silently truncating the body like io.LimitReader
+type oaLimitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	limit     int64
+}
+
+func (b *oaLimitedBody) Read(p []byte) (int, error) {
+	if b.remaining <= 0 {
+		// read one more byte to know if the body is exactly at the limit or larger
+		var probe [1]byte
+		n, err := b.body.Read(probe[:])
+		if n > 0 {
+			return 0, errors.New("response exceeded max bytes (" + strconv.FormatInt(b.limit, 10) + ")")
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > b.remaining {
+		p = p[:b.remaining]
+	}
+
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	return n, err
+}
+
+func (b *oaLimitedBody) Close() error {
+	return b.body.Close()
+}
+
+// oaDecodeResponse read the response body and decode the JSON to v.
+// empty body is checked explicitly, because some proxy or load balancer can return 200 with empty body and the JSON decoder only return io.EOF
+func oaDecodeResponse(resp *http.Response, v interface{}) error {
+	if err := oaCheckContentType(resp, "application/json"); err != nil {
+		return err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("Failed to read response body: %w", err)
+	}
+
+	if len(bytes.TrimSpace(body)) == 0 {
+		return oaEmptyBodyError(resp)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.New("Failed to decode response: " + err.Error())
+	}
+
+	return nil
+}
+
+// oaCheckContentType check the response content type is one of the expected type, so misrouted response like HTML page from
+// captive portal proxy return a descriptive error. expected type ending with "/" (like "audio/") match all subtype.
+// response without content type is accepted because some OpenAI compatible server does not set it
+func oaCheckContentType(resp *http.Response, expected ...string) error {
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return errors.New("invalid response content type " + contentType + " from server (status: " + resp.Status + ")")
+	}
+
+	for _, expectedType := range expected {
+		if mediaType == expectedType || (strings.HasSuffix(expectedType, "/") && strings.HasPrefix(mediaType, expectedType)) {
+			return nil
+		}
+	}
+
+	return errors.New("unexpected response content type " + mediaType + " from server (status: " + resp.Status + ", expected: " + strings.Join(expected, " or ") + ")")
+}
+
+// oaEmptyBodyError create error for response with empty body, the request id is added so the request can be traced on OpenAI side
+func oaEmptyBodyError(resp *http.Response) error {
+	msg := "empty response body from server (status: " + resp.Status
+	if requestID := resp.Header.Get("x-request-id"); requestID != "" {
+		msg += ", request id: " + requestID
+	}
+
+	return errors.New(msg + ")")
+}
+
+// oaRedactHeader copy the request header with the secret value (API key) masked, so it is safe to be logged
+func oaRedactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+
+	if redacted.Get("Authorization") != "" {
+		redacted.Set("Authorization", "Bearer ***")
+	}
+
+	return redacted
+}
+
+// client options for configuring the OpenAI API client
+type ClientOption func(*Config)
+
+// New creates and returns a new instance of the OpenAI API client.
+//
+// This function initializes the OpenAI client with the provided API key, organization, and project ID.
+// Additionally, it applies any optional configuration options passed via the variadic `opts` parameter.
+//
+// Parameters:
+//   - apiKey: The API key used to authenticate with OpenAI's API. This is required and cannot be empty.
+//   - openaiOrganization: (Optional) The organization ID associated with your OpenAI account. This can be omitted if not applicable.
+//   - openaiProject: (Optional) The project ID associated with your OpenAI account. This can be omitted if not applicable.
+//   - opts: A variadic parameter that accepts one or more `ClientOption` functions to customize the client's behavior.
+//
+// Returns:
+//   - An OpenAI client instance, or an error if the API key is missing or another issue occurs during initialization.
+//
+// Default Configuration Values:
+//   - httpClient: The HTTP client used for making requests. By default, the client has a
+//     timeout of 60 seconds (`http.Client{ Timeout: 60 * time.Second }`).
 //   - openAIBaseUrl: The default base URL for the OpenAI is set to the `/chat/completions` endpoint
 //     The default value is `"https://api.openai.com/v1/chat/completions"`.
 //   - openAIModel: The default model for message processing is `"gpt-4o-mini"`, which specifies
@@ -286,6 +1199,7 @@ type ClientOption func(*Config)
 //   - The `apiKey` is required and must be provided, otherwise an error will be returned.
 //   - The `openaiOrganization` and `openaiProject` parameters are optional and can be left empty if not needed.
 //   - `ClientOption` is a functional option pattern that allows customization of the client, such as setting custom HTTP clients or changing API base URLs.
+//   - The returned client is safe for concurrent use, see the OpenAI interface doc for the details.
 //
 // References:
 //   - Official OpenAI API authentication: https://platform.openai.com/docs/api-reference/authentication
@@ -305,6 +1219,10 @@ func New(apiKey string, openaiOrganization string, openaiProject string, opts ..
 		opt(config)
 	}
 
+	if err := config.applyTransportOptions(); err != nil {
+		return nil, err
+	}
+
 	return &openaiAPI{
 		apiKey:             apiKey,
 		openaiOrganization: openaiOrganization,
@@ -334,52 +1252,603 @@ func WithModel(model string) ClientOption {
 	}
 }
 
-// OACreateResponseFormat creates a response format using a JSON Schema for OpenAI response format data requests.
+// audit hook setup, the hook is called for every request after the request is built and before it is sent, use it on New function initiate.
+// the hook receive the method, the final url (after base url override), and a copy of the request header with the Authorization value masked,
+// so it can be used to log where the traffic is routed for security audit
+func WithAuditHook(hook func(method string, url string, header http.Header)) ClientOption {
+	return func(c *Config) {
+		c.auditHook = hook
+	}
+}
+
+// force the http client to use HTTP/1.1, some corporate proxies break HTTP/2 to api.openai.com and cause request or stream stalls.
+// it also apply to the http client from WithHTTPClient if the client use default transport or *http.Transport, use it on New function initiate.
+// New return error if the WithHTTPClient transport is a custom RoundTripper, configure HTTP/1.1 on that RoundTripper instead
+func WithForceHTTP1(force bool) ClientOption {
+	return func(c *Config) {
+		c.forceHTTP1 = force
+	}
+}
+
+// dial (connect) timeout setup for the http client transport, use it on New function initiate.
+// the http client Timeout cover the whole request (dial + TLS + read body), this option only limit the connection dial.
+// New return error if the WithHTTPClient transport is a custom RoundTripper (not *http.Transport)
+func WithDialTimeout(timeout time.Duration) ClientOption {
+	return func(c *Config) {
+		c.dialTimeout = timeout
+	}
+}
+
+// response header timeout setup for the http client transport (time to wait the first response header after the request is sent), use it on New function initiate.
+// for long response like streaming, combine it with http client without Timeout (WithHTTPClient(&http.Client{})) so the request fail fast
+// when the server does not respond but a long response is not cut by the overall deadline.
+// New return error if the WithHTTPClient transport is a custom RoundTripper (not *http.Transport)
+func WithResponseHeaderTimeout(timeout time.Duration) ClientOption {
+	return func(c *Config) {
+		c.responseHeaderTimeout = timeout
+	}
+}
+
+// keep-alive setup for the http client transport, use it on New function initiate.
+// disable it for one-shot process (CLI or serverless function) so each connection is closed after the request and the process does not wait on idle connection at exit.
+// New return error if the WithHTTPClient transport is a custom RoundTripper (not *http.Transport)
+func WithDisableKeepAlives(disable bool) ClientOption {
+	return func(c *Config) {
+		c.disableKeepAlives = disable
+	}
+}
+
+// token limit setup for the default request body on OpenAISendMessage, the limit will be sent as max_tokens or max_completion_tokens based on the model, use it on New function initiate
+func WithMaxTokens(limit int) ClientOption {
+	return func(c *Config) {
+		c.openAIMaxTokens = limit
+	}
+}
+
+// admin key setup for organization admin endpoint like usage (OpenAIGetUsage), this is different key from the normal API key, use it on New function initiate
+func WithAdminKey(adminKey string) ClientOption {
+	return func(c *Config) {
+		c.openAIAdminKey = adminKey
+	}
+}
+
+// prompt cache key setup for the default request body on OpenAISendMessage, use it on New function initiate.
+// request with the same cache key and long same prefix (like fixed system prompt) have better prompt cache hit and lower cost,
+// the cached tokens can be checked on OAUsage.PromptTokensDetails.CachedTokens
+func WithPromptCacheKey(key string) ClientOption {
+	return func(c *Config) {
+		c.promptCacheKey = key
+	}
+}
+
+// fallback model chain setup for OpenAISendMessage, use it on New function initiate.
+// when the request model response with retriable error (429 rate limit or 5xx server error), the same request is sent again with the next model in the chain.
+// the model that served the request can be checked on OAChatCompletionResp.Model
+func WithModelFallback(models ...string) ClientOption {
+	return func(c *Config) {
+		c.fallbackModels = append([]string(nil), models...)
+	}
+}
+
+// max wait between two retry attempts from the backoff, Retry-After header from the server is not limited
+const oaMaxRetryBackoff = 30 * time.Second
+
+// retry setup, use it on New function initiate.
+// request with retriable response (429 rate limit and 500, 502, 503, 504 server error) is sent again up to maxRetries times,
+// the wait is the Retry-After header when the server send it, or exponential backoff from baseDelay with jitter.
+// the wait stop when the request context is cancelled. the number of attempts is on OAAPIError.Attempts when all attempts fail.
+// retry is done per model, the WithModelFallback chain is only used after the retries of the model.
+// use WithRetryIf to choose the retried error by OpenAI error code instead of the status
+func WithRetry(maxRetries int, baseDelay time.Duration) ClientOption {
+	return func(c *Config) {
+		if maxRetries < 0 {
+			maxRetries = 0
+		}
+		if baseDelay <= 0 {
+			baseDelay = 500 * time.Millisecond
+		}
+
+		c.maxRetries = maxRetries
+		c.retryBaseDelay = baseDelay
+	}
+}
+
+// retry condition setup for WithRetry, use it on New function initiate.
+// by default the retriable status (429 and 500, 502, 503, 504) is retried, with this option every non 2xx response is given
+// to the predicate as OAAPIError (StatusCode, Type, Code, and Attempts) and it is sent again only if the predicate return true.
+// use it to decide by the OpenAI error code, e.g. retry "server_error" but not "context_length_exceeded":
 //
-// This function is used to generate a JSON Schema structure that can be passed as a parameter
-// to the OpenAISendMessage() function, providing a standard format for the response.
+//	client, _ := New(apiKey, "", "", WithRetry(3, time.Second), WithRetryIf(func(apiErr *OAAPIError) bool {
+//	    return apiErr.IsRetriable() && !apiErr.IsContextLengthExceeded()
+//	}))
+func WithRetryIf(predicate func(apiErr *OAAPIError) bool) ClientOption {
+	return func(c *Config) {
+		c.retryIf = predicate
+	}
+}
+
+// response body size limit setup in bytes, use it on New function initiate.
+// applied to every response body read by the client (JSON response, error response, and TTS audio),
+// reading a larger body return "response exceeded max bytes" error so a buggy or malicious endpoint can not exhaust the memory
+func WithMaxResponseBytes(limit int64) ClientOption {
+	return func(c *Config) {
+		c.maxResponseBytes = limit
+	}
+}
+
+// usage callback setup for OpenAISendMessage, use it on New function initiate.
+// the callback is called after each successful request with the model that served the request and the token usage,
+// it is not called when the request fail. use it to track the cost on one place without wrapping every call
+func WithUsageCallback(callback func(model string, usage OAUsage)) ClientOption {
+	return func(c *Config) {
+		c.usageCallback = callback
+	}
+}
+
+// model mapper setup for OpenAI compatible backend (gateway or deployment name), use it on New function initiate.
+// the mapper is called with the final model of each request (chat, responses, image, and TTS) just before the request is sent
+// and the returned value is sent as "model". the final model is the model on the request body, or the WithModel default when the chat (including custom body) or responses body has no model,
+// or the fallback model on WithModelFallback retry, so the mapper always has the last word. validation is done on the model before it is mapped.
+// returning empty string keep the original model
+func WithModelMapper(mapper func(model string) string) ClientOption {
+	return func(c *Config) {
+		c.modelMapper = mapper
+	}
+}
+
+// mapModel return the outgoing model value from WithModelMapper, the model is returned as is without mapper
+func (c *openaiAPI) mapModel(model string) string {
+	if c.config.modelMapper == nil {
+		return model
+	}
+
+	if mapped := c.config.modelMapper(model); mapped != "" {
+		return mapped
+	}
+
+	return model
+}
+
+// OARequestStart is the request information passed to the WithRequestHook hook before the request is sent
+type OARequestStart struct {
+	Context context.Context // request context, use it as parent of the tracing span
+	Method  string
+	URL     string
+	Model   string      // model of the request, empty for endpoint without model (e.g. usage, list completions, OpenAIDoJSON)
+	Header  http.Header // request header, header set by the hook is sent (e.g. trace context propagation), do not log it because it contains the API key
+}
+
+// OARequestEnd is the request result passed to the end function returned by the WithRequestHook hook
+type OARequestEnd struct {
+	StatusCode int           // http status code, 0 if the request failed before response
+	RequestID  string        // x-request-id response header, useful to report issue to OpenAI
+	Duration   time.Duration // time until the response header received (body read is not included)
+	Err        error         // transport error (connection, timeout, or cancel), non 200 status is not an error here, check StatusCode
+}
+
+// request hook setup for observability (tracing and metrics), use it on New function initiate.
+// the hook is called before each request is sent and the returned function (can be nil) is called when the response is received or the request fails,
+// so it can be bridged to a tracer without adding the tracer dependency to this package, e.g. with OpenTelemetry:
 //
-// Parameters:
-//   - jsonName: A string representing the name of the JSON schema.
-//   - jsonSchema: A map of string to interface, representing the schema data, specifically the properties
-//     of the schema as defined by the OpenAI structured output documentation.
+//	WithRequestHook(func(start OARequestStart) func(end OARequestEnd) {
+//	    ctx, span := tracer.Start(start.Context, "openai "+start.Method)
+//	    span.SetAttributes(attribute.String("openai.model", start.Model), attribute.String("url.full", start.URL))
+//	    otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(start.Header))
+//	    return func(end OARequestEnd) {
+//	        span.SetAttributes(attribute.Int("http.response.status_code", end.StatusCode))
+//	        if end.Err != nil {
+//	            span.RecordError(end.Err)
+//	        }
+//	        span.End()
+//	    }
+//	})
 //
-// Returns:
-//   - A map[string]interface{} representing the formatted response structure using JSON Schema,
-//     including the schema name and its associated properties.
+// token usage is known after the response body is decoded, use WithUsageCallback together with this hook to record it.
+// the hook can be called from many goroutines at the same time
+func WithRequestHook(hook func(start OARequestStart) func(end OARequestEnd)) ClientOption {
+	return func(c *Config) {
+		c.requestHook = hook
+	}
+}
+
+// context key of the request model, used to pass the model to the request hook
+type oaModelContextKey struct{}
+
+// context key of the attempt number, used to fill OAAPIError.Attempts from the response request
+type oaAttemptsContextKey struct{}
+
+// oaModelContext return the request context with the request model for the request hook
+func oaModelContext(ctx context.Context, model string) context.Context {
+	return context.WithValue(ctx, oaModelContextKey{}, model)
+}
+
+// context key of the per call organization and project override
+type oaOrganizationProjectContextKey struct{}
+
+type oaOrganizationProject struct {
+	organization string
+	project      string
+}
+
+// OAWithOrganizationProject returns a context that overrides the OpenAI-Organization and OpenAI-Project headers for the calls made with it.
 //
-// Example usage:
+// One client (one API key) can be shared by many projects, use it so each call is billed to the right organization or project
+// without creating a client per project. The override is only set on the request headers, the client set on New is not changed
+// and other calls (including concurrent ones) keep sending the New values.
 //
-//		jsonSchema := map[string]interface{}{
-//	 "type": "object",
-//		"properties": map[string]interface{}{
-//			  "title": map[string]interface{}{
-//			    "type": "string",
-//			  },
-//			  "description": map[string]interface{}{
-//			    "type": "string",
-//			  },
-//		   },
-//		}
+// Parameters:
+//   - ctx (context.Context): The parent context of the call.
+//   - organization (string): The organization ID for the call, empty string keeps the organization set on New.
+//   - project (string): The project ID for the call, empty string keeps the project set on New.
 //
-//		formattedResponse := OACreateResponseFormat("MySchema", jsonSchema)
-//		fmt.Printf("Formatted response: %v\n", formattedResponse)
+// Returns:
 //
-// JSON Schema Structure:
-//   - The structure returned by this function will conform to the schema guidelines provided by OpenAI.
-//     More details and examples can be found at the following link:
-//     https://platform.openai.com/docs/guides/structured-outputs/examples
+//	context.Context: The context to pass to the Context function of the call (e.g. OpenAISendMessageContext, OpenAICreateImageDallEContext).
+//	Function without context (e.g. OpenAISendMessage) always send the New values.
 //
-// Returned Structure Example:
+// Example usage:
 //
-//	{
-//	  "type": "json_schema",
-//	  "json_schema": {
-//	    "name": "MySchema",
-//	    "schema": {
-//	      "type": "object",
-//	      "properties": {
-//	        "title": {
+//	ctx := OAWithOrganizationProject(context.Background(), "", "proj_billing_team")
+//	resp, err := client.OpenAISendMessageContext(ctx, &messages, false, nil, false, nil)
+//
+// References:
+//   - Organizations and projects: https://platform.openai.com/docs/api-reference/authentication
+func OAWithOrganizationProject(ctx context.Context, organization string, project string) context.Context {
+	return context.WithValue(ctx, oaOrganizationProjectContextKey{}, oaOrganizationProject{
+		organization: organization,
+		project:      project,
+	})
+}
+
+// last request capture setup, use it on New function initiate.
+// when enabled, the body of each request is kept on the client and can be read with LastRequestBody for debugging.
+// the body is not redacted and can be large (base64 image or audio), so only enable it when needed
+func WithCaptureLastRequest(capture bool) ClientOption {
+	return func(c *Config) {
+		c.captureRequest = capture
+	}
+}
+
+// system prompt setup for OpenAISendMessage, use it on New function initiate.
+// the prompt is added as the first message (role "system") on every request, except if the messages already have a system or developer message
+func WithSystemPrompt(prompt string) ClientOption {
+	return func(c *Config) {
+		c.systemPrompt = prompt
+	}
+}
+
+// context length trimming setup for OpenAISendMessage, use it on New function initiate.
+// when the request fail with "context_length_exceeded", the oldest messages (except system and developer messages and the last message)
+// is dropped and the request is sent once more. the number of dropped messages is on OAChatCompletionResp.TrimmedMessages,
+// 0 means the conversation is sent complete. the caller messages is not modified, drop the same messages on the chat history if needed
+func WithContextTrimming(enabled bool) ClientOption {
+	return func(c *Config) {
+		c.trimOnContextLength = enabled
+	}
+}
+
+// sampling setup for OpenAISendMessage and OpenAISendMessageStream, use it on New function initiate.
+// the params is the default of every chat request so generation can be tuned without custom request body,
+// field already set on the custom request body (with_custom_reqbody) or on the params argument of the call is kept.
+// MaxTokens / MaxCompletionTokens here is used before WithMaxTokens. the range is validated before sending:
+// Temperature 0 to 2, TopP 0 to 1, PresencePenalty -2 to 2, N 1 to 128, and max 4 Stop sequences
+func WithSamplingParams(params OASamplingParams) ClientOption {
+	return func(c *Config) {
+		c.samplingParams = params
+	}
+}
+
+// JSON mode fallback setup for OpenAISendMessage and OpenAISendMessageStream, use it on New function initiate.
+// json_schema response format (structured outputs) on a model that only support json_object (e.g. gpt-4-turbo, gpt-3.5-turbo) fail
+// with error by default, with this option the request is sent with json_object response format and the schema is added to the messages.
+// the model is not guaranteed to follow the schema on JSON mode, validate the output (e.g. OAVerifyNoMissing) before using it
+func WithJSONModeFallback(enabled bool) ClientOption {
+	return func(c *Config) {
+		c.jsonModeFallback = enabled
+	}
+}
+
+// oaValidateMessagesContent check the messages is not empty and user message has content, assistant message can be empty (e.g. tool call only).
+// messages with type other than []OAMessageReq / *[]OAMessageReq is not checked
+func oaValidateMessagesContent(messages interface{}) error {
+	var msgs []OAMessageReq
+	switch m := messages.(type) {
+	case *[]OAMessageReq:
+		if m == nil {
+			return nil
+		}
+		msgs = *m
+	case []OAMessageReq:
+		msgs = m
+	default:
+		return nil
+	}
+
+	if len(msgs) == 0 {
+		return errors.New("messages must not be empty")
+	}
+
+	allEmpty := true
+	for i, msg := range msgs {
+		if msg.Role == "tool" && msg.ToolCallID == "" {
+			return errors.New("tool message " + strconv.Itoa(i) + " must have ToolCallID")
+		}
+
+		empty := oaIsEmptyContent(msg.Content)
+		if empty && msg.Role == "user" {
+			return errors.New("content of user message " + strconv.Itoa(i) + " is empty")
+		}
+
+		if !empty {
+			allEmpty = false
+		}
+	}
+
+	if allEmpty {
+		return errors.New("all messages content is empty")
+	}
+
+	return nil
+}
+
+// oaIsEmptyContent check the message content is nil, whitespace only string, or content parts without text and data
+func oaIsEmptyContent(content interface{}) bool {
+	switch c := content.(type) {
+	case nil:
+		return true
+	case string:
+		return strings.TrimSpace(c) == ""
+	case *string:
+		return c == nil || strings.TrimSpace(*c) == ""
+	case []OAContentVisionBaseReq:
+		for _, part := range c {
+			if part.Text != nil && strings.TrimSpace(*part.Text) != "" {
+				return false
+			}
+			if part.IsImage() || part.InputAudio != nil {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// oaPrependSystemPrompt return new messages with the system prompt as first message, the caller slice is not modified.
+// messages is returned as is if it already has a system or developer message or the type is not []OAMessageReq / *[]OAMessageReq
+func oaPrependSystemPrompt(messages interface{}, prompt string) interface{} {
+	var msgs []OAMessageReq
+	switch m := messages.(type) {
+	case *[]OAMessageReq:
+		if m == nil {
+			return messages
+		}
+		msgs = *m
+	case []OAMessageReq:
+		msgs = m
+	default:
+		return messages
+	}
+
+	for _, msg := range msgs {
+		if msg.Role == "system" || msg.Role == "developer" {
+			return messages
+		}
+	}
+
+	withPrompt := make([]OAMessageReq, 0, len(msgs)+1)
+	withPrompt = append(withPrompt, OAMessageReq{Role: "system", Content: prompt})
+	withPrompt = append(withPrompt, msgs...)
+
+	return &withPrompt
+}
+
+// oaTrimOldestMessages return new messages without the oldest non system messages and the number of dropped messages,
+// the messages is dropped until the estimated tokens is half of the original so the retry fit the context window.
+// system and developer messages and the last message are always kept, the caller slice is not modified.
+// tool result left without the assistant tool call is dropped too because the API reject it
+func oaTrimOldestMessages(messages interface{}) (interface{}, int) {
+	var msgs []OAMessageReq
+	switch m := messages.(type) {
+	case *[]OAMessageReq:
+		if m == nil {
+			return messages, 0
+		}
+		msgs = *m
+	case []OAMessageReq:
+		msgs = m
+	default:
+		return messages, 0
+	}
+
+	total := 0
+	for _, msg := range msgs {
+		total += oaEstimateMessageTokens(msg)
+	}
+
+	drop := make([]bool, len(msgs))
+	dropped := 0
+	remaining := total
+	for i := 0; i < len(msgs)-1 && remaining > total/2; i++ {
+		if msgs[i].Role == "system" || msgs[i].Role == "developer" {
+			continue
+		}
+		drop[i] = true
+		dropped++
+		remaining -= oaEstimateMessageTokens(msgs[i])
+	}
+
+	if dropped == 0 {
+		return messages, 0
+	}
+
+	trimmed := make([]OAMessageReq, 0, len(msgs)-dropped)
+	leading := true
+	for i, msg := range msgs {
+		if drop[i] {
+			continue
+		}
+		if leading && msg.Role == "tool" && i < len(msgs)-1 {
+			dropped++
+			continue
+		}
+		if msg.Role != "system" && msg.Role != "developer" {
+			leading = false
+		}
+		trimmed = append(trimmed, msg)
+	}
+
+	return &trimmed, dropped
+}
+
+// oaEstimateMessageTokens return the rough token count of a message, about 4 characters per token for text
+// and the worst case (high detail) tokens for each image
+func oaEstimateMessageTokens(msg OAMessageReq) int {
+	// every message has a few tokens for the role and separator
+	tokens := 4
+
+	switch c := msg.Content.(type) {
+	case string:
+		tokens += len(c) / 4
+	case *string:
+		if c != nil {
+			tokens += len(*c) / 4
+		}
+	case []OAContentVisionBaseReq:
+		for _, part := range c {
+			if part.Text != nil {
+				tokens += len(*part.Text) / 4
+			}
+			if part.IsImage() {
+				tokens += oaVisionMaxImageTokens
+			}
+		}
+	}
+
+	return tokens
+}
+
+// adaptResponseFormat check the json_schema response format is supported by the model on the capability table.
+// model that only support json_object fail with error, or with WithJSONModeFallback the format is changed to json_object
+// and the schema is added to the messages as system message. model not on the table is sent as is
+func (c *openaiAPI) adaptResponseFormat(reqData *OAReqBodyMessageCompletion) error {
+	if formatType, _ := reqData.ResponseFormat["type"].(string); formatType != "json_schema" {
+		return nil
+	}
+
+	model := c.mapModel(reqData.Model)
+	if oaModelCapabilityPrefix(model) == "" || OAModelSupports(model, OACapabilityStructuredOutput) {
+		return nil
+	}
+
+	if !c.config.jsonModeFallback {
+		return errors.New("model " + model + " does not support json_schema response format, use json_object response format or WithJSONModeFallback")
+	}
+
+	schema := reqData.ResponseFormat["json_schema"]
+	if jsonSchema, ok := schema.(map[string]interface{}); ok && jsonSchema["schema"] != nil {
+		schema = jsonSchema["schema"]
+	}
+
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return errors.New("Failed to marshal json_schema for JSON mode fallback")
+	}
+
+	var msgs []OAMessageReq
+	switch m := reqData.Messages.(type) {
+	case *[]OAMessageReq:
+		if m != nil {
+			msgs = *m
+		}
+	case []OAMessageReq:
+		msgs = m
+	default:
+		return errors.New("JSON mode fallback only support messages with type []OAMessageReq or *[]OAMessageReq")
+	}
+
+	// the caller slice is not modified, the schema message is added on a new slice
+	withSchema := make([]OAMessageReq, 0, len(msgs)+1)
+	withSchema = append(withSchema, OAMessageReq{
+		Role:    "system",
+		Content: "Respond only with a JSON object that follows this JSON schema:\n" + string(schemaJSON),
+	})
+	withSchema = append(withSchema, msgs...)
+
+	reqData.Messages = &withSchema
+	reqData.ResponseFormat = map[string]interface{}{"type": "json_object"}
+
+	return nil
+}
+
+// oaUsesMaxCompletionTokens check if the model need the token limit on max_completion_tokens instead of max_tokens,
+// reasoning models (o1, o3, o4-mini, gpt-5, ...) on the capability table reject the legacy max_tokens field
+func oaUsesMaxCompletionTokens(model string) bool {
+	return OAModelSupports(model, OACapabilityReasoning)
+}
+
+// oaApplyMaxTokens put the token limit on the field name that the request model accept and clear the other one
+func oaApplyMaxTokens(reqBody *OAReqBodyMessageCompletion, limit int) {
+	if limit <= 0 {
+		return
+	}
+
+	if oaUsesMaxCompletionTokens(reqBody.Model) {
+		reqBody.MaxCompletionTokens = limit
+		reqBody.MaxTokens = 0
+	} else {
+		reqBody.MaxTokens = limit
+		reqBody.MaxCompletionTokens = 0
+	}
+}
+
+// OACreateResponseFormat creates a response format using a JSON Schema for OpenAI response format data requests.
+//
+// This function is used to generate a JSON Schema structure that can be passed as a parameter
+// to the OpenAISendMessage() function, providing a standard format for the response.
+//
+// Parameters:
+//   - jsonName: A string representing the name of the JSON schema.
+//   - jsonSchema: A map of string to interface, representing the schema data, specifically the properties
+//     of the schema as defined by the OpenAI structured output documentation.
+//
+// Returns:
+//   - A map[string]interface{} representing the formatted response structure using JSON Schema,
+//     including the schema name and its associated properties.
+//
+// Example usage:
+//
+//		jsonSchema := map[string]interface{}{
+//	 "type": "object",
+//		"properties": map[string]interface{}{
+//			  "title": map[string]interface{}{
+//			    "type": "string",
+//			  },
+//			  "description": map[string]interface{}{
+//			    "type": "string",
+//			  },
+//		   },
+//		}
+//
+//		formattedResponse := OACreateResponseFormat("MySchema", jsonSchema)
+//		fmt.Printf("Formatted response: %v\n", formattedResponse)
+//
+// JSON Schema Structure:
+//   - The structure returned by this function will conform to the schema guidelines provided by OpenAI.
+//     More details and examples can be found at the following link:
+//     https://platform.openai.com/docs/guides/structured-outputs/examples
+//
+// Returned Structure Example:
+//
+//	{
+//	  "type": "json_schema",
+//	  "json_schema": {
+//	    "name": "MySchema",
+//	    "schema": {
+//	      "type": "object",
+//	      "properties": {
+//	        "title": {
 //	          "type": "string"
 //	        },
 //	        "description": {
@@ -399,189 +1868,1961 @@ func OACreateResponseFormat(jsonName string, jsonSchema map[string]interface{})
 	}
 }
 
-// OACreateOneContentVision constructs a vision content payload for uploading an image (either as a URL or base64-encoded string)
-// along with optional text to the OpenAI API.
+// OACreateResponseFormatStrict creates the same response format as OACreateResponseFormat with "strict": true,
+// so the model output is guaranteed to follow the schema (structured outputs).
 //
-// This function creates a list of `OAContentVisionBaseReq` structures, enabling you to upload both image data (via URL or base64 encoding)
-// and optional descriptive text to OpenAI's vision endpoint. Supported media types include JPEG, PNG, JPG, GIF, and WebP.
+// Strict mode requires every object in the schema to list all properties in "required" and set "additionalProperties" to false,
+// the schema from OASchemaFromStruct already follows these rules. ValidateStructuredSchema can be used to check a hand written schema.
+//
+// Example usage:
+//
+//	type Recipe struct {
+//	    Title       string   `json:"title"`
+//	    Ingredients []string `json:"ingredients"`
+//	}
+//
+//	name, schema, err := OASchemaFromStruct(Recipe{})
+//	if err != nil {
+//	    log.Fatalf("Failed to create schema: %v", err)
+//	}
+//
+//	format := OACreateResponseFormatStrict(name, schema)
+//	resp, err := client.OpenAISendMessage(&content, true, &format, false, nil)
+//	if err != nil {
+//	    log.Fatalf("Failed to send message: %v", err)
+//	}
+//
+//	var recipe Recipe
+//	if err := OAUnmarshalContent(resp, &recipe); err != nil {
+//	    log.Fatalf("Failed to decode recipe: %v", err)
+//	}
+//
+// References:
+//   - Structured outputs: https://platform.openai.com/docs/guides/structured-outputs
+func OACreateResponseFormatStrict(jsonName string, jsonSchema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "json_schema",
+		"json_schema": map[string]interface{}{
+			"name":   jsonName,
+			"schema": jsonSchema,
+			"strict": true,
+		},
+	}
+}
+
+// OAUnmarshalContent decodes the structured output (JSON) content of the first choice into `v`.
+//
+// Use this function with a response from a request using response format (`OACreateResponseFormat`), so the content
+// can be decoded straight to your Go struct.
 //
 // Parameters:
-//   - media_type (string): The MIME type of the image when using base64 encoding. This is required when `using_image_url` is false.
-//     Supported types include:
-//   - "image/png"
-//   - "image/jpeg"
-//   - "image/jpg"
-//   - "image/gif"
-//   - "image/webp"
-//   - using_image_url (bool): Specifies whether the image is provided as a URL or a base64-encoded string.
-//   - If `true`, the function expects `url_or_base64encoding` to be a valid URL.
-//   - If `false`, the function expects `url_or_base64encoding` to be a base64-encoded image string, and `media_type` must be provided.
-//   - url_or_base64encoding (string): The image data provided as either a URL (when `using_image_url` is `true`) or a base64-encoded
-//     string (when `using_image_url` is `false`). If this value is empty, the function returns an error indicating that both
-//     `media_type` and `url_or_base64encoding` must be provided.
-//   - text_content (string): An optional text string to accompany the image content. This will be included as a separate text
-//     component if provided.
+//   - resp (*OAChatCompletionResp): The response from `OpenAISendMessage`.
+//   - v (interface{}): Pointer to the value the JSON content is decoded into.
 //
 // Returns:
+//   - An error if the response has no choice, the model refused the request, the response is truncated,
+//     or the content is not valid JSON for `v`.
 //
-//	([]OAContentVisionBaseReq, error): A slice of `OAContentVisionBaseReq` structs containing the image (and optional text).
-//	If the required parameters are not met or the media type is unsupported, an error is returned.
+// Notes:
+//   - When the response hit the token limit (finish_reason "length") the JSON content is truncated and can not be decoded,
+//     in this case a specific error is returned so you can increase the token limit (max_completion_tokens) instead of
+//     getting a vague JSON parse error.
 //
 // Example usage:
 //
-//	// Example URL-based request
-//	visionContent, err := OACreateOneContentVision("", true, "https://example.com/sample-image.jpg", "This is an example image.")
-//	if err != nil {
-//	    log.Fatalf("Error generating vision content: %v", err)
+//	var joke struct {
+//	    Joke string `json:"joke"`
 //	}
-//
-//	// Example base64-encoded request
-//	base64Image := "iVBORw0KGgoAAAANSUhEUgAAAQAAAAEACAYAAABccqhmAAAACXBIWXMAAB7CAAAewgFu0HU+AAAK..." // truncated for example
-//	visionContent, err := OACreateOneContentVision("image/png", false, base64Image, "This is an example image.")
-//	if err != nil {
-//	    log.Fatalf("Error generating vision content: %v", err)
+//	if err := OAUnmarshalContent(resp, &joke); err != nil {
+//	    log.Fatalf("Failed to decode content: %v", err)
 //	}
+func OAUnmarshalContent(resp *OAChatCompletionResp, v interface{}) error {
+	if resp == nil || len(resp.Choices) == 0 {
+		return errors.New("response has no choices")
+	}
+
+	choice := resp.Choices[0]
+
+	if choice.FinishReason == "length" {
+		return errors.New("response truncated; increase max_completion_tokens")
+	}
+
+	if choice.Message.Refusal != "" {
+		return errors.New("model refused the request: " + choice.Message.Refusal)
+	}
+
+	if err := json.Unmarshal([]byte(choice.Message.Content), v); err != nil {
+		return errors.New("Failed to decode content: " + err.Error())
+	}
+
+	return nil
+}
+
+// OAReduceContents combines the first choice content of multiple responses into one result with the reduce function.
 //
-// Function Logic:
-//  1. **Input Validation**: Checks if `url_or_base64encoding` is empty. If it is, returns an error requiring both
-//     `media_type` and `url_or_base64encoding` to be provided. If using base64 encoding (`using_image_url` is false), `media_type`
-//     must also be specified.
-//  2. **Supported Media Types**: Validates that `media_type` is one of the supported image types if `using_image_url` is false.
-//     Supported types include "image/png", "image/jpeg", "image/jpg", "image/gif", and "image/webp". If an unsupported type
-//     is provided, the function returns an error listing the valid types.
-//  3. **Data Preparation**: If `using_image_url` is true, `imageData` is set to `url_or_base64encoding`. Otherwise, `imageData`
-//     is created as a "data URI" by prepending `media_type` and "base64," to the encoded string. This format complies with the
-//     OpenAI API's expectations for base64-encoded images.
-//  4. **Image Content**: A `OAContentVisionBaseReq` struct is created for the image content, setting `Type` to `"image_url"`,
-//     and the image data is assigned to the `ImageUrl` field.
-//  5. **Optional Text Content**: If `text_content` is provided, another `OAContentVisionBaseReq` struct is appended with `Type` set
-//     to `"text"` and `Text` containing the provided text. This allows both image and text content to be sent in a single request.
-//  6. **Return**: Returns the slice of `OAContentVisionBaseReq` structs, which includes the image content (and text content,
-//     if provided), ready for an OpenAI vision request.
+// Use this function for the reduce step of map-reduce processing, e.g. a long document split into chunks where each chunk
+// is sent as one request and the structured output fragments are merged.
 //
-// Notes:
-//   - Ensure that the `url_or_base64encoding` contains a valid URL when `using_image_url` is true or a base64-encoded image string
-//     when false.
-//   - This function supports only a single image and an optional text. Multiple images or additional text content would
-//     require separate calls or modifications to the function.
-//   - OpenAI’s API currently supports base64 and URL images as part of its vision feature, making it possible to use both methods
-//     with this function.
+// Parameters:
+//   - resps ([]*OAChatCompletionResp): The responses to combine, in order. The content of the first response is the initial accumulator.
+//   - reduce (func(acc, next string) (string, error)): Combines the accumulated result with the next response content.
 //
-// Considerations:
-//   - Base64-encoded images should be appropriately sized or compressed before encoding to avoid excessively large requests.
-//   - URLs provided should be publicly accessible or authenticated as needed by the OpenAI API.
-//   - this function hope can make you easier for send vision content if just contain one image and optional text content, if you need more than one image, you can create your own structure based on OpenAI Docs with struct OAContentVisionBaseReq & OAContentVisionImageUrl (for content structure) and append it to the slice of OAContentVisionBaseReq
+// Returns:
+//   - (string, error): The combined result, or an error if there is no response, a response has no choice, or reduce returns an error.
 //
-// Reference for Vision OpenAI Docs:
-// - Official OpenAI API documentation: https://platform.openai.com/docs/guides/vision
-func OACreateOneContentVision(media_type string, using_image_url bool, url_or_base64encoding string, text_content string) ([]OAContentVisionBaseReq, error) {
-	if url_or_base64encoding == "" {
-		return nil, errors.New("media_type and url_or_base64encoding must be provided")
+// Example usage:
+//
+//	merged, err := OAReduceContents(resps, func(acc, next string) (string, error) {
+//	    var a, b Summary
+//	    if err := json.Unmarshal([]byte(acc), &a); err != nil {
+//	        return "", err
+//	    }
+//	    if err := json.Unmarshal([]byte(next), &b); err != nil {
+//	        return "", err
+//	    }
+//	    a.Points = append(a.Points, b.Points...)
+//	    out, err := json.Marshal(a)
+//	    return string(out), err
+//	})
+func OAReduceContents(resps []*OAChatCompletionResp, reduce func(acc string, next string) (string, error)) (string, error) {
+	if len(resps) == 0 {
+		return "", errors.New("resps must be provided")
+	}
+
+	if reduce == nil {
+		return "", errors.New("reduce function must be provided")
+	}
+
+	var acc string
+	for i, resp := range resps {
+		content, err := resp.Content()
+		if err != nil {
+			return "", errors.New("response " + strconv.Itoa(i) + ": " + err.Error())
+		}
+
+		if i == 0 {
+			acc = content
+			continue
+		}
+
+		acc, err = reduce(acc, content)
+		if err != nil {
+			return "", errors.New("Failed to reduce response " + strconv.Itoa(i) + ": " + err.Error())
+		}
 	}
 
-	if media_type == "" && !using_image_url {
-		return nil, errors.New("media_type must be provided when using base64 encoding")
+	return acc, nil
+}
+
+// OAWriteBatchJSONL writes chat completion requests to `w` in the Batch API JSONL input format (one JSON request per line).
+//
+// The output can be saved as a file and uploaded for the Batch API (file purpose "batch") for offline processing.
+//
+// Parameters:
+//   - w (io.Writer): The writer for the JSONL output, e.g. an *os.File.
+//   - requests ([]OABatchRequestItem): The requests, each with a unique `CustomID`. If `Method` or `URL` is empty,
+//     "POST" and "/v1/chat/completions" are used. The token limit on the body is moved to the field the model accepts
+//     (same as `OpenAISendMessage`).
+//
+// Returns:
+//   - An error if a request has empty or duplicate custom id, the body has no model or messages, or writing fails.
+//
+// Example usage:
+//
+//	file, _ := os.Create("batch.jsonl")
+//	defer file.Close()
+//
+//	err := OAWriteBatchJSONL(file, []OABatchRequestItem{
+//	    {CustomID: "request-1", Body: OAReqBodyMessageCompletion{Model: "gpt-4o-mini", Messages: messages1}},
+//	    {CustomID: "request-2", Body: OAReqBodyMessageCompletion{Model: "gpt-4o-mini", Messages: messages2}},
+//	})
+//
+// References:
+//   - Batch API: https://platform.openai.com/docs/guides/batch
+func OAWriteBatchJSONL(w io.Writer, requests []OABatchRequestItem) error {
+	if len(requests) == 0 {
+		return errors.New("requests must be provided")
+	}
+
+	customIDs := make(map[string]bool, len(requests))
+	encoder := json.NewEncoder(w)
+
+	for i, item := range requests {
+		if item.CustomID == "" {
+			return errors.New("request " + strconv.Itoa(i) + ": custom_id must be provided")
+		}
+
+		if customIDs[item.CustomID] {
+			return errors.New("request " + strconv.Itoa(i) + ": duplicate custom_id " + item.CustomID)
+		}
+		customIDs[item.CustomID] = true
+
+		if item.Body.Model == "" || item.Body.Messages == nil {
+			return errors.New("request " + strconv.Itoa(i) + ": body model and messages must be provided")
+		}
+
+		if item.Method == "" {
+			item.Method = http.MethodPost
+		}
+
+		if item.URL == "" {
+			item.URL = "/v1/chat/completions"
+		}
+
+		limit := item.Body.MaxCompletionTokens
+		if limit == 0 {
+			limit = item.Body.MaxTokens
+		}
+		oaApplyMaxTokens(&item.Body, limit)
+
+		// encoder write one JSON per line
+		if err := encoder.Encode(item); err != nil {
+			return errors.New("Failed to write batch request: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// ValidateStructuredSchema validates a JSON schema (the schema passed to `OACreateResponseFormat`) against the OpenAI structured outputs restrictions.
+//
+// Structured outputs (strict mode) only support a subset of JSON schema, and a schema that does not follow the rules is only
+// rejected when the request is sent. This function checks the rules locally and returns a precise error with the schema path.
+//
+// Rules checked:
+//   - The root schema must be type "object".
+//   - Every object must set "additionalProperties": false.
+//   - Every property of an object must be listed in "required" (optional field can use a type array with "null", e.g. ["string", "null"]).
+//   - Supported types: string, number, integer, boolean, object, array, and null. Array must define "items".
+//   - Nesting depth is at most 10 levels. Schemas under "$defs" are also checked, "$ref" is not followed.
+//
+// Returns:
+//   - nil if the schema is valid, otherwise an error like "object at .address missing additionalProperties:false".
+//
+// Example usage:
+//
+//	schema := map[string]interface{}{
+//	    "type": "object",
+//	    "properties": map[string]interface{}{
+//	        "name": map[string]interface{}{"type": "string"},
+//	    },
+//	    "required":             []string{"name"},
+//	    "additionalProperties": false,
+//	}
+//	if err := ValidateStructuredSchema(schema); err != nil {
+//	    log.Fatalf("Invalid schema: %v", err)
+//	}
+//	formatResponse := OACreateResponseFormat("person", schema)
+//
+// References:
+//   - Structured outputs supported schemas: https://platform.openai.com/docs/guides/structured-outputs#supported-schemas
+func ValidateStructuredSchema(schema map[string]interface{}) error {
+	if schema == nil {
+		return errors.New("schema must be provided")
+	}
+
+	if schemaType, _ := schema["type"].(string); schemaType != "object" {
+		return errors.New("schema at . must be type object")
+	}
+
+	if err := oaValidateSchemaNode(schema, "", 0); err != nil {
+		return err
+	}
+
+	if defs, ok := schema["$defs"].(map[string]interface{}); ok {
+		for name, def := range defs {
+			defSchema, ok := def.(map[string]interface{})
+			if !ok {
+				return errors.New("schema at $defs." + name + " must be an object")
+			}
+
+			if err := oaValidateSchemaNode(defSchema, "$defs."+name, 0); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// oaValidateSchemaNode check one schema node and the children (properties, items, anyOf) recursively
+func oaValidateSchemaNode(node map[string]interface{}, path string, depth int) error {
+	displayPath := path
+	if displayPath == "" {
+		displayPath = "."
+	}
+
+	if depth > 10 {
+		return errors.New("schema at " + displayPath + " exceeds max nesting depth of 10")
+	}
+
+	// reference to $defs is checked on the $defs itself
+	if _, ok := node["$ref"]; ok {
+		return nil
+	}
+
+	if anyOf, ok := node["anyOf"].([]interface{}); ok {
+		for i, sub := range anyOf {
+			subSchema, ok := sub.(map[string]interface{})
+			if !ok {
+				return errors.New("anyOf at " + displayPath + " must contain schema objects")
+			}
+
+			if err := oaValidateSchemaNode(subSchema, path+".anyOf["+strconv.Itoa(i)+"]", depth+1); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	// type can be a string or an array of string like ["string", "null"]
+	var types []string
+	switch schemaType := node["type"].(type) {
+	case string:
+		types = []string{schemaType}
+	case []string:
+		types = schemaType
+	case []interface{}:
+		for _, t := range schemaType {
+			if typeName, ok := t.(string); ok {
+				types = append(types, typeName)
+			}
+		}
+	}
+
+	if len(types) == 0 {
+		// enum and const can be used without type
+		_, hasEnum := node["enum"]
+		_, hasConst := node["const"]
+		if hasEnum || hasConst {
+			return nil
+		}
+		return errors.New("schema at " + displayPath + " missing type")
+	}
+
+	for _, schemaType := range types {
+		switch schemaType {
+		case "string", "number", "integer", "boolean", "null":
+
+		case "object":
+			if additionalProperties, ok := node["additionalProperties"].(bool); !ok || additionalProperties {
+				return errors.New("object at " + displayPath + " missing additionalProperties:false")
+			}
+
+			required := map[string]bool{}
+			switch requiredList := node["required"].(type) {
+			case []string:
+				for _, name := range requiredList {
+					required[name] = true
+				}
+			case []interface{}:
+				for _, name := range requiredList {
+					if nameStr, ok := name.(string); ok {
+						required[nameStr] = true
+					}
+				}
+			}
+
+			properties, _ := node["properties"].(map[string]interface{})
+			for name, prop := range properties {
+				if !required[name] {
+					return errors.New("property " + name + " of object at " + displayPath + " must be listed in required")
+				}
+
+				propSchema, ok := prop.(map[string]interface{})
+				if !ok {
+					return errors.New("schema at " + path + "." + name + " must be an object")
+				}
+
+				if err := oaValidateSchemaNode(propSchema, path+"."+name, depth+1); err != nil {
+					return err
+				}
+			}
+
+		case "array":
+			items, ok := node["items"].(map[string]interface{})
+			if !ok {
+				return errors.New("array at " + displayPath + " missing items schema")
+			}
+
+			if err := oaValidateSchemaNode(items, path+"[]", depth+1); err != nil {
+				return err
+			}
+
+		default:
+			return errors.New("schema at " + displayPath + " has unsupported type " + schemaType)
+		}
+	}
+
+	return nil
+}
+
+// OACreateOneContentVision constructs a vision content payload for uploading an image (either as a URL or base64-encoded string)
+// along with optional text to the OpenAI API.
+//
+// This function creates a list of `OAContentVisionBaseReq` structures, enabling you to upload both image data (via URL or base64 encoding)
+// and optional descriptive text to OpenAI's vision endpoint. Supported media types include JPEG, PNG, JPG, GIF, and WebP.
+//
+// Parameters:
+//   - media_type (string): The MIME type of the image when using base64 encoding. If empty when `using_image_url` is false,
+//     the type is detected from the image data (magic number) and an error is returned if it is not a supported image.
+//     Supported types include:
+//   - "image/png"
+//   - "image/jpeg"
+//   - "image/jpg"
+//   - "image/gif"
+//   - "image/webp"
+//   - using_image_url (bool): Specifies whether the image is provided as a URL or a base64-encoded string.
+//   - If `true`, the function expects `url_or_base64encoding` to be a valid URL.
+//   - If `false`, the function expects `url_or_base64encoding` to be a base64-encoded image string, `media_type` can be empty to detect it from the data.
+//   - url_or_base64encoding (string): The image data provided as either a URL (when `using_image_url` is `true`) or a base64-encoded
+//     string (when `using_image_url` is `false`). If this value is empty, the function returns an error indicating that both
+//     `media_type` and `url_or_base64encoding` must be provided.
+//   - text_content (string): An optional text string to accompany the image content. This will be included as a separate text
+//     component if provided.
+//
+// Returns:
+//
+//	([]OAContentVisionBaseReq, error): A slice of `OAContentVisionBaseReq` structs containing the image (and optional text).
+//	If the required parameters are not met or the media type is unsupported, an error is returned.
+//
+// Example usage:
+//
+//	// Example URL-based request
+//	visionContent, err := OACreateOneContentVision("", true, "https://example.com/sample-image.jpg", "This is an example image.")
+//	if err != nil {
+//	    log.Fatalf("Error generating vision content: %v", err)
+//	}
+//
+//	// Example base64-encoded request
+//	base64Image := "iVBORw0KGgoAAAANSUhEUgAAAQAAAAEACAYAAABccqhmAAAACXBIWXMAAB7CAAAewgFu0HU+AAAK..." // truncated for example
+//	visionContent, err := OACreateOneContentVision("image/png", false, base64Image, "This is an example image.")
+//	if err != nil {
+//	    log.Fatalf("Error generating vision content: %v", err)
+//	}
+//
+//	// Example base64-encoded request with detected media type
+//	visionContent, err := OACreateOneContentVision("", false, base64Image, "This is an example image.")
+//
+// Function Logic:
+//  1. **Input Validation**: Checks if `url_or_base64encoding` is empty. If it is, returns an error requiring both
+//     `media_type` and `url_or_base64encoding` to be provided. If using base64 encoding (`using_image_url` is false) and `media_type`
+//     is empty, the media type is detected from the first decoded bytes with `http.DetectContentType`.
+//  2. **Supported Media Types**: Validates that `media_type` is one of the supported image types if `using_image_url` is false.
+//     Supported types include "image/png", "image/jpeg", "image/jpg", "image/gif", and "image/webp". If an unsupported type
+//     is provided, the function returns an error listing the valid types.
+//  3. **Base64 Validation**: If `using_image_url` is false, the data must be decodable with standard base64 encoding and the decoded
+//     bytes (magic number) must match the declared `media_type`. Otherwise a local error is returned before any API call is made.
+//  4. **Data Preparation**: If `using_image_url` is true, `imageData` is set to `url_or_base64encoding`. Otherwise, `imageData`
+//     is created as a "data URI" by prepending `media_type` and "base64," to the encoded string. This format complies with the
+//     OpenAI API's expectations for base64-encoded images.
+//  5. **Image Content**: A `OAContentVisionBaseReq` struct is created for the image content, setting `Type` to `"image_url"`,
+//     and the image data is assigned to the `ImageUrl` field.
+//  6. **Optional Text Content**: If `text_content` is provided, another `OAContentVisionBaseReq` struct is appended with `Type` set
+//     to `"text"` and `Text` containing the provided text. This allows both image and text content to be sent in a single request.
+//  7. **Return**: Returns the slice of `OAContentVisionBaseReq` structs, which includes the image content (and text content,
+//     if provided), ready for an OpenAI vision request.
+//
+// Notes:
+//   - Ensure that the `url_or_base64encoding` contains a valid URL when `using_image_url` is true or a base64-encoded image string
+//     when false.
+//   - This function supports only a single image and an optional text, use OACreateMultiContentVision for many images
+//     and texts in one message.
+//   - OpenAI’s API currently supports base64 and URL images as part of its vision feature, making it possible to use both methods
+//     with this function.
+//
+// Considerations:
+//   - Base64-encoded images should be appropriately sized or compressed before encoding to avoid excessively large requests.
+//   - URLs provided should be publicly accessible or authenticated as needed by the OpenAI API.
+//   - this function hope can make you easier for send vision content if just contain one image and optional text content, if you need more than one image, use OACreateMultiContentVision
+//
+// Reference for Vision OpenAI Docs:
+// - Official OpenAI API documentation: https://platform.openai.com/docs/guides/vision
+func OACreateOneContentVision(media_type string, using_image_url bool, url_or_base64encoding string, text_content string) ([]OAContentVisionBaseReq, error) {
+	imagePart, err := oaCreateImagePart(media_type, using_image_url, url_or_base64encoding)
+	if err != nil {
+		return nil, err
+	}
+
+	contentVision := []OAContentVisionBaseReq{imagePart}
+
+	if text_content != "" {
+		contentVision = append(contentVision, OAContentVisionBaseReq{
+			Type: "text",
+			Text: &text_content,
+		})
+	}
+
+	return contentVision, nil
+}
+
+// oaCreateImagePart validate the image and create the image content part, base64 image is sent as data URI.
+// empty media_type of base64 image is detected from the image data
+func oaCreateImagePart(media_type string, using_image_url bool, url_or_base64encoding string) (OAContentVisionBaseReq, error) {
+	if url_or_base64encoding == "" {
+		return OAContentVisionBaseReq{}, errors.New("media_type and url_or_base64encoding must be provided")
+	}
+
+	// media type not provided, detect it from the image data
+	if media_type == "" && !using_image_url {
+		detectedType, err := oaDetectImageMediaType(url_or_base64encoding)
+		if err != nil {
+			return OAContentVisionBaseReq{}, err
+		}
+		media_type = detectedType
+	}
+
+	if !using_image_url && media_type != "image/png" && media_type != "image/jpeg" && media_type != "image/jpg" && media_type != "image/gif" && media_type != "image/webp" {
+		return OAContentVisionBaseReq{}, errors.New("media_type must be image/png, image/jpeg, or image/jpg")
+	}
+
+	// check the base64 data before sending, so truncated or wrong data is caught locally instead of by the API
+	if !using_image_url {
+		if err := oaValidateImageBase64(media_type, url_or_base64encoding); err != nil {
+			return OAContentVisionBaseReq{}, err
+		}
+	}
+
+	var imageData string
+
+	// data url or base64 encoding and the format is based on OpenAI API Docs
+	if using_image_url {
+		imageData = url_or_base64encoding
+	} else {
+		imageData = "data:" + media_type + ";base64," + url_or_base64encoding
+	}
+
+	return OAContentVisionBaseReq{
+		Type: "image_url",
+		ImageUrl: &OAContentVisionImageUrl{
+			Url: imageData,
+		},
+	}, nil
+}
+
+// OAVisionImage is one image for OACreateMultiContentVision
+type OAVisionImage struct {
+	MediaType string // MIME type of base64 image, detected from the data if empty, not used for URL image
+	UsingURL  bool   // true if Data is image URL, false if Data is base64 encoded image
+	Data      string // image URL or base64 encoded image
+}
+
+// OACreateMultiContentVision constructs a vision content payload with many images and texts in one message,
+// e.g. "compare these two charts" with two images.
+//
+// The texts and images are interleaved in order: texts[0], images[0], texts[1], images[1], ..., and the remaining texts or images
+// of the longer slice are added at the end. Empty text is skipped, so an empty string can be used to put two images next to each other.
+// Each image is validated the same way as OACreateOneContentVision (supported media type, base64 data match the media type).
+//
+// Parameters:
+//   - texts ([]string): The text parts, can be empty if the message only has images.
+//   - images ([]OAVisionImage): The images, at least one image is required.
+//
+// Returns:
+//
+//	([]OAContentVisionBaseReq, error): The content parts in order. An error with the image index is returned if an image is invalid.
+//
+// Example usage:
+//
+//	content, err := OACreateMultiContentVision(
+//	    []string{"Compare these two charts.", "This is the chart of last year."},
+//	    []OAVisionImage{
+//	        {UsingURL: true, Data: "https://example.com/chart-2025.png"},
+//	        {MediaType: "image/png", Data: base64Chart2024},
+//	    },
+//	)
+//	if err != nil {
+//	    log.Fatalf("Error generating vision content: %v", err)
+//	}
+//	messages := []OAMessageReq{{Role: "user", Content: content}}
+//
+// Reference for Vision OpenAI Docs:
+// - Official OpenAI API documentation: https://platform.openai.com/docs/guides/vision
+func OACreateMultiContentVision(texts []string, images []OAVisionImage) ([]OAContentVisionBaseReq, error) {
+	if len(images) == 0 {
+		return nil, errors.New("images must have at least one image")
+	}
+
+	contentVision := make([]OAContentVisionBaseReq, 0, len(texts)+len(images))
+	for i := 0; i < len(texts) || i < len(images); i++ {
+		if i < len(texts) && texts[i] != "" {
+			text := texts[i]
+			contentVision = append(contentVision, OAContentVisionBaseReq{
+				Type: "text",
+				Text: &text,
+			})
+		}
+
+		if i < len(images) {
+			imagePart, err := oaCreateImagePart(images[i].MediaType, images[i].UsingURL, images[i].Data)
+			if err != nil {
+				return nil, fmt.Errorf("image %d: %w", i, err)
+			}
+			contentVision = append(contentVision, imagePart)
+		}
+	}
+
+	return contentVision, nil
+}
+
+// OAParseTranscript converts a conversation transcript text into a message slice that can be sent with OpenAISendMessage.
+//
+// Each message starts with a role prefix on the beginning of the line: "User:", "Assistant:", "System:", or "Developer:" (case insensitive).
+// Lines without role prefix are treated as continuation of the previous message, so multi line message is supported.
+//
+// Parameters:
+//   - transcript (string): The conversation text, e.g. "User: Hello\nAssistant: Hi, how can I help?\nUser: Tell me a joke".
+//
+// Returns:
+//
+//	([]OAMessageReq, error): The message slice in the transcript order. An error is returned if the transcript is empty,
+//	the first line does not have a role prefix, or a message has empty content (the error contains the line number).
+//
+// Example usage:
+//
+//	messages, err := OAParseTranscript("User: Hello\nAssistant: Hi there!\nUser: Give me a joke")
+//	if err != nil {
+//	    log.Fatalf("Failed to parse transcript: %v", err)
+//	}
+//	resp, err := client.OpenAISendMessage(&messages, false, nil, false, nil)
+func OAParseTranscript(transcript string) ([]OAMessageReq, error) {
+	rolePrefixes := map[string]string{
+		"user:":      "user",
+		"assistant:": "assistant",
+		"system:":    "system",
+		"developer:": "developer",
+	}
+
+	var messages []OAMessageReq
+	var contentLines []string
+	messageLine := 0
+
+	// add the current message to the list, called when new role prefix found and at the end of transcript
+	flush := func() error {
+		if len(messages) == 0 {
+			return nil
+		}
+
+		content := strings.TrimSpace(strings.Join(contentLines, "\n"))
+		if content == "" {
+			return errors.New("transcript line " + strconv.Itoa(messageLine) + ": message content is empty")
+		}
+
+		messages[len(messages)-1].Content = content
+		return nil
+	}
+
+	for i, line := range strings.Split(strings.ReplaceAll(transcript, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		role := ""
+		for prefix, prefixRole := range rolePrefixes {
+			if len(trimmed) >= len(prefix) && strings.EqualFold(trimmed[:len(prefix)], prefix) {
+				role = prefixRole
+				trimmed = trimmed[len(prefix):]
+				break
+			}
+		}
+
+		if role == "" {
+			if len(messages) == 0 {
+				if trimmed == "" {
+					continue
+				}
+				return nil, errors.New("transcript line " + strconv.Itoa(i+1) + ": missing role prefix (User:, Assistant:, System:, or Developer:)")
+			}
+
+			contentLines = append(contentLines, line)
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return nil, err
+		}
+
+		messages = append(messages, OAMessageReq{Role: role})
+		contentLines = []string{trimmed}
+		messageLine = i + 1
+	}
+
+	if len(messages) == 0 {
+		return nil, errors.New("transcript is empty")
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// OACreateContentAudio constructs an audio input content payload for sending voice directly to an audio chat model (e.g. gpt-4o-audio-preview).
+//
+// The returned slice can be used directly as the `Content` of an `OAMessageReq`, and can be appended with text content
+// (`OAContentVisionBaseReq` with type "text") if you need to send a text instruction together with the audio.
+//
+// Parameters:
+//   - base64Data (string): The base64 encoded audio data. The data must be valid standard base64 encoding.
+//   - format (string): The audio format, supported format: "wav" and "mp3".
+//
+// Returns:
+//
+//	([]OAContentVisionBaseReq, error): A slice with one content of type "input_audio". An error is returned if the data is empty,
+//	not valid base64, or the format is not supported.
+//
+// Example usage:
+//
+//	audioContent, err := OACreateContentAudio(base64Audio, "wav")
+//	if err != nil {
+//	    log.Fatalf("Error generating audio content: %v", err)
+//	}
+//
+//	messages := []OAMessageReq{
+//	    {Role: "user", Content: audioContent},
+//	}
+//
+// References:
+//   - Audio input OpenAI Docs: https://platform.openai.com/docs/guides/audio
+func OACreateContentAudio(base64Data string, format string) ([]OAContentVisionBaseReq, error) {
+	if base64Data == "" {
+		return nil, errors.New("base64Data must be provided")
+	}
+
+	if format != "wav" && format != "mp3" {
+		return nil, errors.New("format must be wav or mp3")
+	}
+
+	if _, err := base64.StdEncoding.DecodeString(base64Data); err != nil {
+		return nil, errors.New("invalid base64 audio data: " + err.Error())
+	}
+
+	return []OAContentVisionBaseReq{
+		{
+			Type: "input_audio",
+			InputAudio: &OAContentInputAudio{
+				Data:   base64Data,
+				Format: format,
+			},
+		},
+	}, nil
+}
+
+// OAEstimateVisionTokens estimates the input token cost of one image on vision request based on the image size and detail level.
+//
+// This can be used to choose the cheapest acceptable detail level for the image (set on `OAContentVisionImageUrl.Detail`).
+// The estimation uses the OpenAI tiling formula for gpt-4o family models:
+//   - "low": fixed 85 tokens, the image is processed on 512x512 low resolution.
+//   - "high": the image is scaled to fit 2048x2048, then scaled so the shortest side is 768px, then counted as 512px tiles.
+//     The cost is 85 + 170 tokens for each tile.
+//   - "auto" (or empty): the model decides, estimated as "low" for image that fit 512x512 and "high" for bigger image.
+//
+// Returns:
+//   - The estimated token count, or 0 if width or height is not positive or the detail is not low, high, or auto.
+//
+// Example usage:
+//
+//	low := OAEstimateVisionTokens(1920, 1080, "low")   // 85
+//	high := OAEstimateVisionTokens(1920, 1080, "high") // 1105
+//
+// References:
+//   - Vision cost calculation: https://platform.openai.com/docs/guides/images-vision#calculating-costs
+func OAEstimateVisionTokens(width int, height int, detail string) int {
+	if width <= 0 || height <= 0 {
+		return 0
+	}
+
+	if detail == "" || detail == "auto" {
+		detail = "high"
+		if width <= 512 && height <= 512 {
+			detail = "low"
+		}
+	}
+
+	switch detail {
+	case "low":
+		return 85
+
+	case "high":
+		w, h := float64(width), float64(height)
+
+		// fit within 2048 x 2048 square
+		if w > 2048 || h > 2048 {
+			scale := 2048 / math.Max(w, h)
+			w, h = w*scale, h*scale
+		}
+
+		// scale so the shortest side is 768px
+		if math.Min(w, h) > 768 {
+			scale := 768 / math.Min(w, h)
+			w, h = w*scale, h*scale
+		}
+
+		tiles := int(math.Ceil(w/512)) * int(math.Ceil(h/512))
+		return 85 + 170*tiles
+
+	default:
+		return 0
+	}
+}
+
+// oaDetectImageMediaType detect the media type from the first bytes of base64 image data, error if the data is not a supported image
+func oaDetectImageMediaType(base64Data string) (string, error) {
+	// http.DetectContentType only read the first 512 bytes, 684 base64 characters are enough to decode them
+	prefix := base64Data
+	if len(prefix) > 684 {
+		prefix = prefix[:684]
+	}
+
+	headBytes, err := base64.StdEncoding.DecodeString(prefix)
+	if err != nil {
+		return "", errors.New("invalid base64 image data: " + err.Error())
+	}
+
+	detectedType := http.DetectContentType(headBytes)
+	if !oaIsSupportedImageType(detectedType) {
+		return "", errors.New("unsupported image data detected as " + detectedType + ", must be image/png, image/jpeg, image/gif, or image/webp")
+	}
+
+	return detectedType, nil
+}
+
+// oaValidateImageBase64 check the base64 image data can be decoded and the decoded bytes (magic number) match the declared media type
+func oaValidateImageBase64(media_type string, base64Data string) error {
+	fileBytes, err := base64.StdEncoding.DecodeString(base64Data)
+	if err != nil {
+		return errors.New("invalid base64 image data: " + err.Error())
+	}
+
+	return oaValidateImageBytes(media_type, fileBytes)
+}
+
+// oaValidateImageBytes check the image bytes (magic number) match the declared media type
+func oaValidateImageBytes(media_type string, fileBytes []byte) error {
+	// image/jpg is not a real mime type but accepted as alias of image/jpeg
+	if media_type == "image/jpg" {
+		media_type = "image/jpeg"
+	}
+
+	detectedType := http.DetectContentType(fileBytes)
+	if detectedType != media_type {
+		return errors.New("image data does not match media_type: declared " + media_type + " but data is " + detectedType)
+	}
+
+	return nil
+}
+
+// oaIsSupportedImageType check the media type is supported for vision input
+func oaIsSupportedImageType(media_type string) bool {
+	switch media_type {
+	case "image/png", "image/jpeg", "image/jpg", "image/gif", "image/webp":
+		return true
+	}
+
+	return false
+}
+
+// OAImageSource is one image for OAVisionMessage, create it with OAImageFromURL, OAImageFromFile, or OAImageFromBytes
+type OAImageSource struct {
+	url       string
+	filePath  string
+	data      []byte
+	mediaType string
+}
+
+// OAImageFromURL create image source from a public image url
+func OAImageFromURL(imageUrl string) OAImageSource {
+	return OAImageSource{url: imageUrl}
+}
+
+// OAImageFromFile create image source from a local image file, the file is read and sent as base64 data, the media type is detected from the file content
+func OAImageFromFile(filePath string) OAImageSource {
+	return OAImageSource{filePath: filePath}
+}
+
+// OAImageFromBytes create image source from image bytes with the media type (image/png, image/jpeg, image/gif, or image/webp), sent as base64 data
+func OAImageFromBytes(data []byte, mediaType string) OAImageSource {
+	return OAImageSource{data: data, mediaType: mediaType}
+}
+
+// resolve validate the source and return the url for the image content (image url or base64 data url)
+func (src OAImageSource) resolve() (string, error) {
+	switch {
+	case src.url != "":
+		if !strings.HasPrefix(src.url, "http://") && !strings.HasPrefix(src.url, "https://") {
+			return "", errors.New("image url must start with http:// or https://: " + src.url)
+		}
+		return src.url, nil
+
+	case src.filePath != "":
+		fileBytes, err := os.ReadFile(src.filePath)
+		if err != nil {
+			return "", errors.New("Failed to read image file: " + err.Error())
+		}
+
+		mediaType := http.DetectContentType(fileBytes)
+		if !oaIsSupportedImageType(mediaType) {
+			return "", errors.New("image file " + src.filePath + " is not supported image type (png, jpeg, gif, or webp), detected: " + mediaType)
+		}
+		return "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(fileBytes), nil
+
+	case len(src.data) > 0:
+		if !oaIsSupportedImageType(src.mediaType) {
+			return "", errors.New("media type must be image/png, image/jpeg, image/jpg, image/gif, or image/webp")
+		}
+
+		if err := oaValidateImageBytes(src.mediaType, src.data); err != nil {
+			return "", err
+		}
+		return "data:" + src.mediaType + ";base64," + base64.StdEncoding.EncodeToString(src.data), nil
+	}
+
+	return "", errors.New("image source is empty")
+}
+
+// OAVisionMessage builds one user message with a text and any number of images from mixed sources (url, local file, or bytes).
+//
+// Each source is validated and resolved to the correct content: url is sent as it is, local file and bytes are sent as base64 data url.
+// The text is placed first and followed by the images in the given order.
+//
+// Parameters:
+//   - text (string): The text (question or instruction) for the images, can be empty if only images are sent.
+//   - sources (...OAImageSource): The images, created with `OAImageFromURL`, `OAImageFromFile`, or `OAImageFromBytes`. At least one image is required.
+//
+// Returns:
+//
+//	(OAMessageReq, error): A user message ready to be sent. An error is returned if no image is provided, a url is not http(s),
+//	a file can not be read or is not a supported image, or the bytes do not match the declared media type.
+//
+// Example usage:
+//
+//	message, err := OAVisionMessage("What is the difference between these images?",
+//	    OAImageFromURL("https://example.com/chart-2023.png"),
+//	    OAImageFromURL("https://example.com/chart-2024.png"),
+//	    OAImageFromFile("./chart-2025.png"),
+//	)
+//	if err != nil {
+//	    log.Fatalf("Error creating vision message: %v", err)
+//	}
+//	messages := []OAMessageReq{message}
+//
+// References:
+//   - Vision OpenAI Docs: https://platform.openai.com/docs/guides/vision
+func OAVisionMessage(text string, sources ...OAImageSource) (OAMessageReq, error) {
+	if len(sources) == 0 {
+		return OAMessageReq{}, errors.New("at least one image source must be provided")
+	}
+
+	var content []OAContentVisionBaseReq
+
+	if text != "" {
+		content = append(content, OAContentVisionBaseReq{
+			Type: "text",
+			Text: &text,
+		})
+	}
+
+	for i, src := range sources {
+		imageData, err := src.resolve()
+		if err != nil {
+			return OAMessageReq{}, errors.New("image source " + strconv.Itoa(i) + ": " + err.Error())
+		}
+
+		content = append(content, OAContentVisionBaseReq{
+			Type: "image_url",
+			ImageUrl: &OAContentVisionImageUrl{
+				Url: imageData,
+			},
+		})
+	}
+
+	return OAMessageReq{
+		Role:    "user",
+		Content: content,
+	}, nil
+}
+
+// worst case token of one image with auto detail, used when the image size is unknown (url or webp image).
+// high detail image is scaled to fit 2048x2048 with the shortest side 768px, so the max is 768x2048 (2 x 4 tiles)
+const oaVisionMaxImageTokens = 85 + 170*8
+
+// OAEstimateVisionSourcesTokens estimates the total input token cost of the images with `OAEstimateVisionTokens` (auto detail).
+//
+// The image size of local file and bytes is read from the image header (png, jpeg, gif). The size of url and webp image
+// can not be known without downloading it, so the worst case of one image (1445 tokens) is used and the estimate is an upper bound.
+//
+// Parameters:
+//   - sources (...OAImageSource): The images, created with `OAImageFromURL`, `OAImageFromFile`, or `OAImageFromBytes`.
+//
+// Returns:
+//   - (int, error): The estimated total tokens, or an error if a local file can not be read.
+//
+// Example usage:
+//
+//	tokens, err := OAEstimateVisionSourcesTokens(OAImageFromFile("./a.png"), OAImageFromURL("https://example.com/b.png"))
+func OAEstimateVisionSourcesTokens(sources ...OAImageSource) (int, error) {
+	total := 0
+	for i, src := range sources {
+		data := src.data
+		if src.filePath != "" {
+			fileBytes, err := os.ReadFile(src.filePath)
+			if err != nil {
+				return 0, errors.New("image source " + strconv.Itoa(i) + ": Failed to read image file: " + err.Error())
+			}
+			data = fileBytes
+		}
+
+		if len(data) == 0 {
+			total += oaVisionMaxImageTokens
+			continue
+		}
+
+		config, _, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil {
+			// format without decoder (webp) is counted as worst case
+			total += oaVisionMaxImageTokens
+			continue
+		}
+
+		total += OAEstimateVisionTokens(config.Width, config.Height, "auto")
+	}
+
+	return total, nil
+}
+
+// OAVisionMessageWithBudget builds the same message as `OAVisionMessage` after checking the estimated image tokens are within the budget.
+//
+// Many high detail images can exceed the context window or the cost limit, this function returns a local error before the request is sent.
+// The estimate is from `OAEstimateVisionSourcesTokens` (url image is counted as worst case).
+//
+// Parameters:
+//   - text (string): The text (question or instruction) for the images, can be empty if only images are sent.
+//   - budget (int): Max total estimated image tokens, must be positive.
+//   - sources (...OAImageSource): The images. At least one image is required.
+//
+// Returns:
+//
+//	(OAMessageReq, error): A user message ready to be sent, or an error if the estimate exceeds the budget or OAVisionMessage fails.
+//
+// Example usage:
+//
+//	message, err := OAVisionMessageWithBudget("Compare these charts", 4000,
+//	    OAImageFromFile("./chart-2024.png"),
+//	    OAImageFromFile("./chart-2025.png"),
+//	)
+//	if err != nil {
+//	    log.Fatalf("Error creating vision message: %v", err)
+//	}
+func OAVisionMessageWithBudget(text string, budget int, sources ...OAImageSource) (OAMessageReq, error) {
+	if budget <= 0 {
+		return OAMessageReq{}, errors.New("budget must be positive")
+	}
+
+	tokens, err := OAEstimateVisionSourcesTokens(sources...)
+	if err != nil {
+		return OAMessageReq{}, err
+	}
+
+	if tokens > budget {
+		return OAMessageReq{}, errors.New("estimated image tokens " + strconv.Itoa(tokens) + " exceed the budget " + strconv.Itoa(budget) + ", use fewer or smaller images")
+	}
+
+	return OAVisionMessage(text, sources...)
+}
+
+// ValidateImageRequest validates the DALL-E image generation request parameters without sending the request.
+//
+// This is the same validation used by `OpenAICreateImageDallE` before sending the request, so it can be used to pre-check
+// a batch of image requests or validate user input on a UI without any HTTP call.
+//
+// Validation:
+//   - Model must be "dall-e-2" or "dall-e-3".
+//   - N must be between 1 and 10.
+//   - Quality ("standard" or "hd") and Style ("vivid" or "natural") are only supported for dall-e-3.
+//   - ResponseFormat must be "url" or "b64_json".
+//
+// Prompt and Size are checked by the OpenAI API, not by this function.
+//
+// Returns:
+//   - nil if the request is valid, otherwise an error describing the first invalid parameter.
+//
+// Example usage:
+//
+//	for _, req := range imageRequests {
+//	    if err := ValidateImageRequest(&req); err != nil {
+//	        fmt.Println("invalid request:", err)
+//	        continue
+//	    }
+//	    // queue the valid request
+//	}
+func ValidateImageRequest(req_body *OAReqImageGeneratorDallE) error {
+	if req_body == nil {
+		return errors.New("request body must be provided")
+	}
+
+	if req_body.Model == "" || (req_body.Model != "dall-e-2" && req_body.Model != "dall-e-3") {
+		return errors.New("Model must be dall-e-2 or dall-e-3")
+	}
+
+	if req_body.N != nil && (*req_body.N < 1 || *req_body.N > 10) {
+		return errors.New("N must be between 1 and 10")
+	}
+
+	if req_body.Model != "dall-e-3" && req_body.Quality != nil {
+		return errors.New("Quality is only supported for dall-e-3 model")
+	}
+
+	if req_body.Quality != nil && (*req_body.Quality != "standard" && *req_body.Quality != "hd") {
+		return errors.New("Quality must be standard or hd")
+	}
+
+	if req_body.Model != "dall-e-3" && req_body.Style != nil {
+		return errors.New("Style is only supported for dall-e-3 model")
+	}
+
+	if req_body.Style != nil && (*req_body.Style != "vivid" && *req_body.Style != "natural") {
+		return errors.New("Style must be vivid or natural")
+	}
+
+	if req_body.ResponseFormat != nil && (*req_body.ResponseFormat != "url" && *req_body.ResponseFormat != "b64_json") {
+		return errors.New("ResponseFormat must be url or b64_json")
+	}
+
+	return nil
+}
+
+// OADALLESizeFor maps an aspect ratio name to the DALL-E size string supported by the model, so the size can be set on `OAReqImageGeneratorDallE`.
+//
+// Parameters:
+//   - model (string): "dall-e-2" or "dall-e-3".
+//   - aspect (string): "square", "landscape", or "portrait".
+//
+// Returns:
+//
+//	(string, error): The size string for the model:
+//	- dall-e-2: square "1024x1024" (dall-e-2 only support square image, landscape and portrait return an error).
+//	- dall-e-3: square "1024x1024", landscape "1792x1024", portrait "1024x1792".
+//	An error is returned if the model or aspect is not supported.
+//
+// Example usage:
+//
+//	size, err := OADALLESizeFor("dall-e-3", "landscape")
+//	if err != nil {
+//	    log.Fatalf("Unsupported size: %v", err)
+//	}
+//	reqBody := OAReqImageGeneratorDallE{Model: "dall-e-3", Prompt: "A mountain at sunrise", Size: &size}
+func OADALLESizeFor(model string, aspect string) (string, error) {
+	sizes := map[string]map[string]string{
+		"dall-e-2": {
+			"square": "1024x1024",
+		},
+		"dall-e-3": {
+			"square":    "1024x1024",
+			"landscape": "1792x1024",
+			"portrait":  "1024x1792",
+		},
+	}
+
+	modelSizes, ok := sizes[model]
+	if !ok {
+		return "", errors.New("Model must be dall-e-2 or dall-e-3")
+	}
+
+	if aspect != "square" && aspect != "landscape" && aspect != "portrait" {
+		return "", errors.New("aspect must be square, landscape, or portrait")
+	}
+
+	size, ok := modelSizes[aspect]
+	if !ok {
+		return "", errors.New(aspect + " aspect is not supported for " + model + " model")
+	}
+
+	return size, nil
+}
+
+// OASafeFilename creates a deterministic filesystem safe name from a prompt, for the file name of generated audio or image.
+//
+// The prompt is lowercased, every character other than a-z and 0-9 is replaced with "-" (repeated "-" is collapsed and trimmed),
+// and the result is truncated to maxLen. The result never contains path separator or "..", so untrusted prompt can not change the directory.
+//
+// Parameters:
+//   - prompt (string): The prompt or text used to generate the file.
+//   - maxLen (int): Max length of the name without extension, 64 is used if maxLen <= 0.
+//
+// Returns:
+//   - string: The safe name without extension, "output" if the prompt has no safe character.
+//
+// Example usage:
+//
+//	name := OASafeFilename("A cute cat, sitting on ../../etc!", 40) // "a-cute-cat-sitting-on-etc"
+//	os.WriteFile(name+resp.FormatAudio, audioBytes, 0644)
+func OASafeFilename(prompt string, maxLen int) string {
+	if maxLen <= 0 {
+		maxLen = 64
+	}
+
+	var name strings.Builder
+	lastDash := true // skip leading "-"
+	for _, r := range strings.ToLower(prompt) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			name.WriteRune(r)
+			lastDash = false
+			continue
+		}
+
+		if !lastDash {
+			name.WriteByte('-')
+			lastDash = true
+		}
+	}
+
+	safeName := name.String()
+	if len(safeName) > maxLen {
+		safeName = safeName[:maxLen]
+	}
+	safeName = strings.Trim(safeName, "-")
+
+	if safeName == "" {
+		return "output"
+	}
+
+	return safeName
+}
+
+func (c *openaiAPI) OpenAISendMessage(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAChatCompletionResp, error) {
+	return c.OpenAISendMessageContext(context.Background(), content, with_format_response, format_response, with_custom_reqbody, req_body_custom, params...)
+}
+
+func (c *openaiAPI) OpenAISendMessageContext(ctx context.Context, content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAChatCompletionResp, error) {
+	reqData, limit, err := c.buildChatRequestBody(content, with_format_response, format_response, with_custom_reqbody, req_body_custom, params)
+	if err != nil {
+		return nil, err
+	}
+
+	models := c.chatModels(reqData.Model)
+
+	result, err := c.sendChatWithFallback(ctx, &reqData, models, limit)
+	if err == nil || !c.config.trimOnContextLength {
+		return result, err
+	}
+
+	// conversation longer than the model context window, drop the oldest messages and send once more
+	var apiErr *OAAPIError
+	if !errors.As(err, &apiErr) || !apiErr.IsContextLengthExceeded() {
+		return nil, err
+	}
+
+	trimmed, dropped := oaTrimOldestMessages(reqData.Messages)
+	if dropped == 0 {
+		return nil, err
+	}
+	reqData.Messages = trimmed
+
+	result, err = c.sendChatWithFallback(ctx, &reqData, models, limit)
+	if err != nil {
+		return nil, err
+	}
+	result.TrimmedMessages = dropped
+
+	return result, nil
+}
+
+// chatModels return the models sent for the chat request, the request model first and then the different models of the fallback chain
+func (c *openaiAPI) chatModels(model string) []string {
+	models := []string{model}
+	for _, fallback := range c.config.fallbackModels {
+		if fallback != "" && fallback != model {
+			models = append(models, fallback)
+		}
+	}
+
+	return models
+}
+
+// sendChatWithFallback send the chat request with the models in order until one succeed,
+// the next model is only tried when the failure is retriable (rate limit or server error)
+func (c *openaiAPI) sendChatWithFallback(ctx context.Context, reqData *OAReqBodyMessageCompletion, models []string, limit int) (*OAChatCompletionResp, error) {
+	var lastErr error
+	for i, model := range models {
+		// token limit field and response format depend on the model, so they are applied on a copy for each model in the chain
+		attemptReq := *reqData
+		attemptReq.Model = model
+		oaApplyMaxTokens(&attemptReq, limit)
+		if err := c.adaptResponseFormat(&attemptReq); err != nil {
+			lastErr = err
+			break
+		}
+
+		result, err := c.sendChatCompletion(ctx, &attemptReq)
+		if err == nil {
+			if c.config.usageCallback != nil {
+				servedModel := result.Model
+				if servedModel == "" {
+					servedModel = model
+				}
+				c.config.usageCallback(servedModel, result.Usage)
+			}
+
+			return result, nil
+		}
+		lastErr = err
+
+		// only try the next model if the failure is from the model capacity, other error will fail the same way on other model
+		var apiErr *OAAPIError
+		if !errors.As(err, &apiErr) || !apiErr.IsRetriable() || i == len(models)-1 {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// buildChatRequestBody validate the OpenAISendMessage parameters and create the request body (copy of the custom body or the default body),
+// the token limit is returned separately because the field is set per model with oaApplyMaxTokens
+func (c *openaiAPI) buildChatRequestBody(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params []OASamplingParams) (OAReqBodyMessageCompletion, int, error) {
+	if c.apiKey == "" {
+		return OAReqBodyMessageCompletion{}, 0, errors.New("API Key is empty")
+	}
+
+	// check if with_format_response is true, format_response must be provided
+	if with_format_response && format_response == nil {
+		return OAReqBodyMessageCompletion{}, 0, errors.New("format_response must be provided when with_format_response is true")
+	}
+
+	// check if with_custom_reqbody is true, req_body_custom must be provided
+	if with_custom_reqbody && req_body_custom.Messages == nil {
+		return OAReqBodyMessageCompletion{}, 0, errors.New("req_body_custom must be provided when with_custom_reqbody is true")
+	}
+
+	// empty message content is mostly a bug on the caller (user input not captured), so it is rejected before spending a request
+	messages := interface{}(content)
+	if with_custom_reqbody {
+		messages = req_body_custom.Messages
+	}
+	if err := oaValidateMessagesContent(messages); err != nil {
+		return OAReqBodyMessageCompletion{}, 0, err
+	}
+
+	if with_custom_reqbody {
+		for _, tool := range req_body_custom.Tools {
+			if tool.Type != "function" {
+				return OAReqBodyMessageCompletion{}, 0, errors.New("tool type must be function")
+			}
+			if !oaToolNameRegex.MatchString(tool.Function.Name) {
+				return OAReqBodyMessageCompletion{}, 0, errors.New("tool name must be 1-64 characters of a-z, A-Z, 0-9, underscore, or dash")
+			}
+		}
+
+		if toolChoice, ok := req_body_custom.ToolChoice.(string); ok && toolChoice != "none" && toolChoice != "auto" && toolChoice != "required" {
+			return OAReqBodyMessageCompletion{}, 0, errors.New("ToolChoice must be none, auto, required, or function object")
+		}
+
+		if req_body_custom.TopLogprobs != nil && (!req_body_custom.Logprobs || *req_body_custom.TopLogprobs < 0 || *req_body_custom.TopLogprobs > 20) {
+			return OAReqBodyMessageCompletion{}, 0, errors.New("TopLogprobs must be between 0 and 20 and need Logprobs true")
+		}
+	}
+
+	// format_response would overwrite the response format of the custom body, the caller must choose one of them
+	if with_custom_reqbody && with_format_response && len(req_body_custom.ResponseFormat) > 0 {
+		return OAReqBodyMessageCompletion{}, 0, errors.New("req_body_custom already has ResponseFormat, set with_format_response to false or remove ResponseFormat from req_body_custom")
+	}
+
+	// check if with_custom_reqbody is false, content must be provided
+	if !with_custom_reqbody && content == nil {
+		return OAReqBodyMessageCompletion{}, 0, errors.New("content must be provided")
+	}
+
+	// create request body
+	var reqData OAReqBodyMessageCompletion
+	var limit int
+	if with_custom_reqbody {
+		// copy the custom body so the caller struct is not changed by the adjustment below
+		reqData = *req_body_custom
+
+		// custom body without model use the WithModel default, same as CreateResponse
+		if reqData.Model == "" {
+			reqData.Model = c.config.openAIModel
+		}
+
+		if with_format_response {
+			reqData.ResponseFormat = *format_response
+		}
+
+	} else {
+		reqData = OAReqBodyMessageCompletion{
+			Model:    c.config.openAIModel,
+			Messages: content,
+		}
+
+		// if using format response add response format to request body
+		if with_format_response {
+			reqData.ResponseFormat = *format_response
+		}
+
+		reqData.PromptCacheKey = c.config.promptCacheKey
+	}
+
+	if c.config.systemPrompt != "" {
+		reqData.Messages = oaPrependSystemPrompt(reqData.Messages, c.config.systemPrompt)
+	}
+
+	// field set on the custom body is kept, then the per call params (the last one first) and the client params fill the rest
+	for i := len(params) - 1; i >= 0; i-- {
+		oaApplySamplingParams(&reqData, params[i])
+	}
+	oaApplySamplingParams(&reqData, c.config.samplingParams)
+	if err := oaValidateSamplingParams(&reqData); err != nil {
+		return OAReqBodyMessageCompletion{}, 0, err
+	}
+
+	// user can set the limit on max_tokens or max_completion_tokens, move it to the field the model accept
+	limit = reqData.MaxCompletionTokens
+	if limit == 0 {
+		limit = reqData.MaxTokens
+	}
+	if limit == 0 && !with_custom_reqbody {
+		limit = c.config.openAIMaxTokens
+	}
+
+	return reqData, limit, nil
+}
+
+// oaApplySamplingParams set the sampling params on the request body field that is not set yet
+func oaApplySamplingParams(reqData *OAReqBodyMessageCompletion, params OASamplingParams) {
+	if reqData.Temperature == nil {
+		reqData.Temperature = params.Temperature
+	}
+	if reqData.TopP == nil {
+		reqData.TopP = params.TopP
+	}
+	if reqData.MaxTokens == 0 && reqData.MaxCompletionTokens == 0 {
+		reqData.MaxTokens = params.MaxTokens
+		reqData.MaxCompletionTokens = params.MaxCompletionTokens
+	}
+	if reqData.N == nil {
+		reqData.N = params.N
+	}
+	if reqData.Stop == nil {
+		reqData.Stop = params.Stop
+	}
+	if reqData.PresencePenalty == nil {
+		reqData.PresencePenalty = params.PresencePenalty
+	}
+	if reqData.Seed == nil {
+		reqData.Seed = params.Seed
+	}
+}
+
+// oaValidateSamplingParams check the sampling params range, out of range value is rejected by OpenAI after the request is sent
+func oaValidateSamplingParams(reqData *OAReqBodyMessageCompletion) error {
+	if reqData.Temperature != nil && (*reqData.Temperature < 0 || *reqData.Temperature > 2) {
+		return errors.New("Temperature must be between 0 and 2")
+	}
+
+	if reqData.TopP != nil && (*reqData.TopP < 0 || *reqData.TopP > 1) {
+		return errors.New("TopP must be between 0 and 1")
+	}
+
+	if reqData.MaxTokens < 0 || reqData.MaxCompletionTokens < 0 {
+		return errors.New("MaxTokens and MaxCompletionTokens must not be negative")
+	}
+
+	if reqData.N != nil && (*reqData.N < 1 || *reqData.N > 128) {
+		return errors.New("N must be between 1 and 128")
+	}
+
+	if reqData.PresencePenalty != nil && (*reqData.PresencePenalty < -2 || *reqData.PresencePenalty > 2) {
+		return errors.New("PresencePenalty must be between -2 and 2")
+	}
+
+	if len(reqData.Stop) > 4 {
+		return errors.New("Stop must have at most 4 sequences")
+	}
+
+	return nil
+}
+
+// sendChatCompletion send the prepared request body to chat completions endpoint and decode the response
+func (c *openaiAPI) sendChatCompletion(ctx context.Context, reqData *OAReqBodyMessageCompletion) (*OAChatCompletionResp, error) {
+	reqBody := *reqData
+	reqBody.Model = c.mapModel(reqBody.Model)
+
+	reqBodyJSON, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, errors.New("Failed to marshal request body")
+	}
+
+	// send req to openai
+	req, err := http.NewRequestWithContext(oaModelContext(ctx, reqBody.Model), http.MethodPost, c.config.openAIBaseUrl, bytes.NewBuffer(reqBodyJSON))
+	if err != nil {
+		return nil, errors.New("Failed to create request")
+	}
+
+	// header setup
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	c.setRequestHeaders(req)
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send request: %w", err)
+	}
+	defer func() {
+		if resp.StatusCode != http.StatusOK {
+			io.ReadAll(resp.Body)
+		}
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, oaNewAPIError(resp, c.config.clock.Now())
+	}
+
+	// decode response
+	var result OAChatCompletionResp
+	if err := oaDecodeResponse(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil // return response
+}
+
+func (c *openaiAPI) OpenAIGetFirstContentDataResp(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAMessage, error) {
+	return c.OpenAIGetFirstContentDataRespContext(context.Background(), content, with_format_response, format_response, with_custom_reqbody, req_body_custom, params...)
+}
+
+func (c *openaiAPI) OpenAIGetFirstContentDataRespContext(ctx context.Context, content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAMessage, error) {
+	// send request to openai
+	resp, err := c.OpenAISendMessageContext(ctx, content, with_format_response, format_response, with_custom_reqbody, req_body_custom, params...)
+	if err != nil {
+		return nil, err
+	}
+
+	// empty choices return error instead of panic
+	if _, err := resp.Content(); err != nil {
+		return nil, err
+	}
+
+	// get content first data
+	data := resp.Choices[0].Message
+
+	return &data, nil
+}
+
+func (c *openaiAPI) OpenAICreateImageDallE(req_body *OAReqImageGeneratorDallE) (*OAImageGeneratorDallEResp, error) {
+	return c.OpenAICreateImageDallEContext(context.Background(), req_body)
+}
+
+func (c *openaiAPI) OpenAICreateImageDallEContext(ctx context.Context, req_body *OAReqImageGeneratorDallE) (*OAImageGeneratorDallEResp, error) {
+
+	// ----------- input checker request
+	if err := ValidateImageRequest(req_body); err != nil {
+		return nil, err
+	}
+
+	apiKey := c.apiKey
+	if apiKey == "" {
+		return nil, errors.New("API Key is empty")
+	}
+
+	// copy the request so the mapped model is not set on the caller struct
+	reqData := *req_body
+	reqData.Model = c.mapModel(reqData.Model)
+
+	reqBodyJson, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, errors.New("Failed to marshal request body")
+	}
+
+	// create and send request
+	req, err := http.NewRequestWithContext(oaModelContext(ctx, reqData.Model), http.MethodPost, OAUrlImageGenerationsDallE, bytes.NewBuffer(reqBodyJson))
+	if err != nil {
+		return nil, errors.New("Failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	c.setRequestHeaders(req)
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send request: %w", err)
+	}
+	defer func() {
+		if resp.StatusCode != http.StatusOK {
+			io.ReadAll(resp.Body)
+		}
+		resp.Body.Close()
+	}()
+
+	// error body from image generation contain actionable message like content policy violation
+	if resp.StatusCode != http.StatusOK {
+		return nil, oaNewAPIError(resp, c.config.clock.Now())
+	}
+
+	var respDataDallE OAImageGeneratorDallEResp
+	if err := oaDecodeResponse(resp, &respDataDallE); err != nil {
+		return nil, err
+	}
+
+	return &respDataDallE, nil
+}
+
+func (c *openaiAPI) OpenAITextToSpeech(req_body *OAReqTextToSpeech) (*OATextToSpeechResp, error) {
+	return c.OpenAITextToSpeechContext(context.Background(), req_body)
+}
+
+func (c *openaiAPI) OpenAITextToSpeechContext(ctx context.Context, req_body *OAReqTextToSpeech) (*OATextToSpeechResp, error) {
+	fileBytes, err := c.textToSpeechBytes(ctx, req_body)
+	if err != nil {
+		return nil, err
+	}
+
+	// because from the docs will be return file extension for audio, so for the response will be base64 encoded version of the audio we received
+	var b64audio, fileExt string
+	b64audio = base64.StdEncoding.EncodeToString(fileBytes)
+
+	if req_body.ResponseFormat == "" {
+		fileExt = ".mp3"
+	} else {
+		fileExt = "." + req_body.ResponseFormat
+	}
+
+	result := OATextToSpeechResp{
+		B64JSON:     b64audio,
+		FormatAudio: fileExt,
+	}
+
+	return &result, nil
+}
+
+// textToSpeechBytes validate and send the TTS request, return the audio bytes
+func (c *openaiAPI) textToSpeechBytes(ctx context.Context, req_body *OAReqTextToSpeech) ([]byte, error) {
+
+	// ----------- input checker request
+	if req_body.Model == "" || (req_body.Model != "tts-1" && req_body.Model != "tts-1-hd") {
+		return nil, errors.New("Model must be gpt-3 or davinci")
+	}
+
+	if req_body.Input == "" {
+		return nil, errors.New("Input text must be provided")
+	}
+
+	if req_body.Voice != "" && !oaTextToSpeechVoices[req_body.Voice] {
+		return nil, errors.New("Voice must be alloy, echo, fable, onyx, nova, or shimmer")
+	}
+
+	if req_body.ResponseFormat != "" && !oaTextToSpeechFormats[req_body.ResponseFormat] {
+		return nil, errors.New("ResponseFormat must be mp3, opus, aac, flac, wav, or pcm")
+	}
+
+	if req_body.Speed != nil && (*req_body.Speed < 0.25 || *req_body.Speed > 4.0) {
+		return nil, errors.New("Speed must be between 0.25 and 4.0")
+	}
+
+	apiKey := c.apiKey
+	if apiKey == "" {
+		return nil, errors.New("API Key is empty")
+	}
+
+	// copy the request so the mapped model is not set on the caller struct
+	reqData := *req_body
+	reqData.Model = c.mapModel(reqData.Model)
+
+	// empty format is the mp3 default, sent explicitly because the field has no omitempty
+	if reqData.ResponseFormat == "" {
+		reqData.ResponseFormat = "mp3"
+	}
+
+	// create json ver for req body
+	reqBodyJson, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, errors.New("Failed to marshal request body")
+	}
+
+	// create req
+	req, err := http.NewRequestWithContext(oaModelContext(ctx, reqData.Model), http.MethodPost, OAUrlTextToSpeech, bytes.NewBuffer(reqBodyJson))
+	if err != nil {
+		return nil, errors.New("Failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "audio/*")
+	c.setRequestHeaders(req)
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send request: %w", err)
+	}
+	defer func() {
+		if resp.StatusCode != http.StatusOK {
+			io.ReadAll(resp.Body)
+		}
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, oaNewAPIError(resp, c.config.clock.Now())
+	}
+
+	if err := oaCheckContentType(resp, "audio/", "application/octet-stream"); err != nil {
+		return nil, err
+	}
+
+	fileBytes, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read response body3: %w", err)
+	}
+
+	if len(fileBytes) == 0 {
+		return nil, oaEmptyBodyError(resp)
+	}
+
+	return fileBytes, nil
+}
+
+func (c *openaiAPI) OpenAITextToSpeechLong(req_body *OAReqTextToSpeech, w io.Writer) error {
+	return c.OpenAITextToSpeechLongContext(context.Background(), req_body, w)
+}
+
+func (c *openaiAPI) OpenAITextToSpeechLongContext(ctx context.Context, req_body *OAReqTextToSpeech, w io.Writer) error {
+	if req_body == nil {
+		return errors.New("req_body must be provided")
+	}
+
+	if w == nil {
+		return errors.New("writer must be provided")
+	}
+
+	// container format (opus, flac, wav) has a header on each file, so the segments audio can not be joined by concatenating the bytes
+	if req_body.ResponseFormat != "" && req_body.ResponseFormat != "mp3" && req_body.ResponseFormat != "aac" && req_body.ResponseFormat != "pcm" {
+		return errors.New("ResponseFormat must be mp3, aac, or pcm for long text speech")
+	}
+
+	segments := oaSplitSpeechInput(req_body.Input, oaTextToSpeechMaxInput)
+	if len(segments) == 0 {
+		return errors.New("Input text must be provided")
+	}
+
+	for i, segment := range segments {
+		if err := ctx.Err(); err != nil {
+			return fmt.Errorf("speech cancelled before segment %d of %d: %w", i+1, len(segments), err)
+		}
+
+		// copy the request so each segment use the same setup and the caller struct is not changed
+		segmentReq := *req_body
+		segmentReq.Input = segment
+
+		audio, err := c.textToSpeechBytes(ctx, &segmentReq)
+		if err != nil {
+			return fmt.Errorf("Failed to create speech of segment %d of %d: %w", i+1, len(segments), err)
+		}
+
+		if _, err := w.Write(audio); err != nil {
+			return errors.New("Failed to write audio: " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// voices supported by the TTS models (tts-1 and tts-1-hd)
+var oaTextToSpeechVoices = map[string]bool{
+	"alloy":   true,
+	"echo":    true,
+	"fable":   true,
+	"onyx":    true,
+	"nova":    true,
+	"shimmer": true,
+}
+
+// audio formats supported by the TTS endpoint
+var oaTextToSpeechFormats = map[string]bool{
+	"mp3":  true,
+	"opus": true,
+	"aac":  true,
+	"flac": true,
+	"wav":  true,
+	"pcm":  true,
+}
+
+// max input characters of one TTS request
+const oaTextToSpeechMaxInput = 4096
+
+// oaSplitSpeechInput split the text into segments of max limit characters, the split is done at sentence end (. ! ? or new line),
+// a sentence longer than the limit is split at space, and a word longer than the limit is split at the limit
+func oaSplitSpeechInput(text string, limit int) []string {
+	var sentences []string
+	runes := []rune(text)
+	start := 0
+	for i, r := range runes {
+		isEnd := r == '\n' || ((r == '.' || r == '!' || r == '?') && (i+1 == len(runes) || unicode.IsSpace(runes[i+1])))
+		if isEnd {
+			sentences = append(sentences, string(runes[start:i+1]))
+			start = i + 1
+		}
+	}
+	if start < len(runes) {
+		sentences = append(sentences, string(runes[start:]))
 	}
 
-	if !using_image_url && media_type != "image/png" && media_type != "image/jpeg" && media_type != "image/jpg" && media_type != "image/gif" && media_type != "image/webp" {
-		return nil, errors.New("media_type must be image/png, image/jpeg, or image/jpg")
+	var segments []string
+	var current []rune
+	flush := func() {
+		if segment := strings.TrimSpace(string(current)); segment != "" {
+			segments = append(segments, segment)
+		}
+		current = current[:0]
 	}
 
-	var imageData string
+	for _, sentence := range sentences {
+		sentenceRunes := []rune(sentence)
+		if len(current)+len(sentenceRunes) <= limit {
+			current = append(current, sentenceRunes...)
+			continue
+		}
 
-	// data url or base64 encoding and the format is based on OpenAI API Docs
-	if using_image_url {
-		imageData = url_or_base64encoding
-	} else {
-		imageData = "data:" + media_type + ";base64," + url_or_base64encoding
-	}
+		flush()
 
-	contentVision := []OAContentVisionBaseReq{
-		{
-			Type: "image_url",
-			ImageUrl: &OAContentVisionImageUrl{
-				Url: imageData,
-			},
-		},
-	}
+		// long sentence is split at the last space before the limit
+		for len(sentenceRunes) > limit {
+			cut := limit
+			for j := limit; j > 0; j-- {
+				if unicode.IsSpace(sentenceRunes[j]) {
+					cut = j
+					break
+				}
+			}
 
-	if text_content != "" {
-		contentVision = append(contentVision, OAContentVisionBaseReq{
-			Type: "text",
-			Text: &text_content,
-		})
+			current = append(current, sentenceRunes[:cut]...)
+			flush()
+			sentenceRunes = sentenceRunes[cut:]
+		}
+
+		current = append(current, sentenceRunes...)
 	}
+	flush()
 
-	return contentVision, nil
+	return segments
 }
 
-func (c *openaiAPI) OpenAISendMessage(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion) (*OAChatCompletionResp, error) {
-
-	// var reqBody interface{}
-	var reqBody interface{}
+func (c *openaiAPI) OpenAISpeechToTextRaw(body io.Reader, contentType string) ([]byte, error) {
+	return c.OpenAISpeechToTextRawContext(context.Background(), body, contentType)
+}
 
+func (c *openaiAPI) OpenAISpeechToTextRawContext(ctx context.Context, body io.Reader, contentType string) ([]byte, error) {
 	if c.apiKey == "" {
 		return nil, errors.New("API Key is empty")
 	}
 
-	// check if with_format_response is true, format_response must be provided
-	if with_format_response && format_response == nil {
-		return nil, errors.New("format_response must be provided when with_format_response is true")
+	if body == nil {
+		return nil, errors.New("body must be provided")
 	}
 
-	// check if with_custom_reqbody is true, req_body_custom must be provided
-	if with_custom_reqbody && req_body_custom.Messages == nil {
-		return nil, errors.New("req_body_custom must be provided when with_custom_reqbody is true")
+	// the transcription endpoint only accept multipart form, the boundary is needed to parse the body
+	if !strings.HasPrefix(contentType, "multipart/form-data") || !strings.Contains(contentType, "boundary=") {
+		return nil, errors.New("contentType must be multipart/form-data with boundary")
 	}
 
-	// check if with_custom_reqbody is false, content must be provided
-	if !with_custom_reqbody && content == nil {
-		return nil, errors.New("content must be provided")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, OAUrlAudioTranscriptions, body)
+	if err != nil {
+		return nil, errors.New("Failed to create request")
 	}
 
-	// create request body
-	if with_custom_reqbody {
+	req.Header.Set("Content-Type", contentType)
+	c.setRequestHeaders(req)
 
-		if with_format_response {
-			req_body_custom.ResponseFormat = *format_response
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send request: %w", err)
+	}
+	defer func() {
+		if resp.StatusCode != http.StatusOK {
+			io.ReadAll(resp.Body)
 		}
+		resp.Body.Close()
+	}()
 
-		reqBody = req_body_custom
+	if resp.StatusCode != http.StatusOK {
+		return nil, oaNewAPIError(resp, c.config.clock.Now())
+	}
 
-	} else {
-		reqData := OAReqBodyMessageCompletion{
-			Model:    c.config.openAIModel,
-			Messages: content,
-		}
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read response body: %w", err)
+	}
 
-		// if using format response add response format to request body
-		if with_format_response {
-			reqData.ResponseFormat = *format_response
-		}
+	if len(bytes.TrimSpace(respBody)) == 0 {
+		return nil, oaEmptyBodyError(resp)
+	}
+
+	return respBody, nil
+}
+
+func (c *openaiAPI) OpenAIGetUsage(ctx context.Context, start time.Time, end time.Time) (*OAUsageResp, error) {
 
-		reqBody = reqData
+	// ----------- input checker request
+	adminKey := c.config.openAIAdminKey
+	if adminKey == "" {
+		return nil, errors.New("Admin Key is empty, usage endpoint need an organization admin key (use WithAdminKey)")
 	}
 
-	reqBodyJSON, err := json.Marshal(reqBody)
-	if err != nil {
-		return nil, errors.New("Failed to marshal request body")
+	if start.IsZero() {
+		return nil, errors.New("start time must be provided")
 	}
 
-	// send req to openai
-	req, err := http.NewRequest(http.MethodPost, c.config.openAIBaseUrl, bytes.NewBuffer(reqBodyJSON))
-	if err != nil {
-		return nil, errors.New("Failed to create request")
+	if !end.IsZero() && !end.After(start) {
+		return nil, errors.New("end time must be after start time")
 	}
 
-	// header setup
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	result := OAUsageResp{
+		Object: "page",
+	}
+
+	// usage endpoint is paginated, so fetch all page and merge the buckets
+	nextPage := ""
+	for {
+		query := url.Values{}
+		query.Set("start_time", strconv.FormatInt(start.Unix(), 10))
+		if !end.IsZero() {
+			query.Set("end_time", strconv.FormatInt(end.Unix(), 10))
+		}
+		if nextPage != "" {
+			query.Set("page", nextPage)
+		}
+
+		// relative to the API root so WithBaseUrl (proxy or gateway) is used, same as OAUrlUsageCompletions by default
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.apiRoot()+"/organization/usage/completions?"+query.Encode(), nil)
+		if err != nil {
+			return nil, errors.New("Failed to create request")
+		}
 
-	client := c.config.httpClient
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		c.setRequestHeaders(req)
+		// usage endpoint need the admin key instead of the API key, the organization and project headers are kept
+		req.Header.Set("Authorization", "Bearer "+adminKey)
 
-	resp, err := client.Do(req)
+		page, err := c.getUsagePage(req)
+		if err != nil {
+			return nil, err
+		}
+
+		result.Data = append(result.Data, page.Data...)
+
+		if !page.HasMore || page.NextPage == "" {
+			break
+		}
+		nextPage = page.NextPage
+	}
+
+	return &result, nil
+}
+
+// getUsagePage send one usage page request and decode the page response
+func (c *openaiAPI) getUsagePage(req *http.Request) (*oaUsagePageResp, error) {
+	resp, err := c.sendRequest(req)
 	if err != nil {
-		return nil, errors.New("Failed to send request: " + err.Error())
+		return nil, fmt.Errorf("Failed to send request: %w", err)
 	}
 	defer func() {
 		if resp.StatusCode != http.StatusOK {
@@ -591,86 +3832,132 @@ func (c *openaiAPI) OpenAISendMessage(content *[]OAMessageReq, with_format_respo
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("Failed to send request: " + resp.Status)
+		return nil, oaNewAPIError(resp, c.config.clock.Now())
 	}
 
-	// decode response
-	var result OAChatCompletionResp
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, errors.New("Failed to decode response: " + err.Error())
+	var page oaUsagePageResp
+	if err := oaDecodeResponse(resp, &page); err != nil {
+		return nil, err
 	}
 
-	return &result, nil // return response
+	return &page, nil
 }
 
-func (c *openaiAPI) OpenAIGetFirstContentDataResp(content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion) (*OAMessage, error) {
-	// send request to openai
-	resp, err := c.OpenAISendMessage(content, with_format_response, format_response, with_custom_reqbody, req_body_custom)
-	if err != nil {
-		return nil, err
+func (c *openaiAPI) OpenAIDoJSON(ctx context.Context, method string, path string, body interface{}, out interface{}) error {
+
+	if c.apiKey == "" {
+		return errors.New("API Key is empty")
 	}
 
-	// get content first data
-	data := resp.Choices[0].Message
+	if method == "" {
+		return errors.New("method must be provided")
+	}
 
-	return &data, nil
-}
+	// full url is used as it is, otherwise path is relative to the API root
+	reqUrl := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		reqUrl = c.apiRoot() + "/" + strings.TrimPrefix(path, "/")
+	}
 
-func (c *openaiAPI) OpenAICreateImageDallE(req_body *OAReqImageGeneratorDallE) (*OAImageGeneratorDallEResp, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBodyJson, err := json.Marshal(body)
+		if err != nil {
+			return errors.New("Failed to marshal request body")
+		}
+		reqBody = bytes.NewBuffer(reqBodyJson)
+	}
 
-	// ----------- input checker request
-	if req_body.Model == "" || (req_body.Model != "dall-e-2" && req_body.Model != "dall-e-3") {
-		return nil, errors.New("Model must be dall-e-2 or dall-e-3")
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, reqBody)
+	if err != nil {
+		return errors.New("Failed to create request")
 	}
 
-	if req_body.N != nil && (*req_body.N < 1 || *req_body.N > 10) {
-		return nil, errors.New("N must be between 1 and 10")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
 	}
+	req.Header.Set("Accept", "application/json")
+	c.setRequestHeaders(req)
 
-	if req_body.Model != "dall-e-3" && req_body.Quality != nil {
-		return nil, errors.New("Quality is only supported for dall-e-3 model")
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return fmt.Errorf("Failed to send request: %w", err)
 	}
+	defer func() {
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}()
 
-	if req_body.Quality != nil && (*req_body.Quality != "standard" && *req_body.Quality != "hd") {
-		return nil, errors.New("Quality must be standard or hd")
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return oaNewAPIError(resp, c.config.clock.Now())
 	}
 
-	if req_body.Model != "dall-e-3" && req_body.Style != nil {
-		return nil, errors.New("Style is only supported for dall-e-3 model")
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
 	}
 
-	if req_body.Style != nil && (*req_body.Style != "vivid" && *req_body.Style != "natural") {
-		return nil, errors.New("Style must be vivid or natural")
+	if err := oaDecodeResponse(resp, out); err != nil {
+		return err
 	}
 
-	if req_body.ResponseFormat != nil && (*req_body.ResponseFormat != "url" && *req_body.ResponseFormat != "b64_json") {
-		return nil, errors.New("ResponseFormat must be url or b64_json")
+	return nil
+}
+
+func (c *openaiAPI) OpenAICreateResponse(req_body *OAResponseReq) (*OAResponseResp, error) {
+	return c.OpenAICreateResponseContext(context.Background(), req_body)
+}
+
+func (c *openaiAPI) OpenAICreateResponseContext(ctx context.Context, req_body *OAResponseReq) (*OAResponseResp, error) {
+
+	// ----------- input checker request
+	if req_body == nil || req_body.Input == nil {
+		return nil, errors.New("Input must be provided")
 	}
 
-	apiKey := c.apiKey
-	if apiKey == "" {
+	if input, ok := req_body.Input.(string); ok && input == "" {
+		return nil, errors.New("Input must be provided")
+	}
+
+	if req_body.Reasoning != nil {
+		effort := req_body.Reasoning.Effort
+		if effort != "" && effort != "minimal" && effort != "low" && effort != "medium" && effort != "high" {
+			return nil, errors.New("Reasoning effort must be minimal, low, medium, or high")
+		}
+
+		summary := req_body.Reasoning.Summary
+		if summary != "" && summary != "auto" && summary != "concise" && summary != "detailed" {
+			return nil, errors.New("Reasoning summary must be auto, concise, or detailed")
+		}
+	}
+
+	if c.apiKey == "" {
 		return nil, errors.New("API Key is empty")
 	}
 
-	reqBodyJson, err := json.Marshal(req_body)
+	// copy the request so the default model is not set on the caller struct
+	reqData := *req_body
+	if reqData.Model == "" {
+		reqData.Model = c.config.openAIModel
+	}
+	reqData.Model = c.mapModel(reqData.Model)
+
+	reqBodyJson, err := json.Marshal(reqData)
 	if err != nil {
 		return nil, errors.New("Failed to marshal request body")
 	}
 
-	// create and send request
-	req, err := http.NewRequest(http.MethodPost, OAUrlImageGenerationsDallE, bytes.NewBuffer(reqBodyJson))
+	req, err := http.NewRequestWithContext(oaModelContext(ctx, reqData.Model), http.MethodPost, OAUrlResponses, bytes.NewBuffer(reqBodyJson))
 	if err != nil {
 		return nil, errors.New("Failed to create request")
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
-
-	client := c.config.httpClient
+	req.Header.Set("Accept", "application/json")
+	c.setRequestHeaders(req)
 
-	resp, err := client.Do(req)
+	resp, err := c.sendRequest(req)
 	if err != nil {
-		return nil, errors.New("Failed to send request: " + err.Error())
+		return nil, fmt.Errorf("Failed to send request: %w", err)
 	}
 	defer func() {
 		if resp.StatusCode != http.StatusOK {
@@ -680,65 +3967,116 @@ func (c *openaiAPI) OpenAICreateImageDallE(req_body *OAReqImageGeneratorDallE) (
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("Failed to send request: " + resp.Status)
+		return nil, oaNewAPIError(resp, c.config.clock.Now())
 	}
 
-	var respDataDallE OAImageGeneratorDallEResp
-	if err := json.NewDecoder(resp.Body).Decode(&respDataDallE); err != nil {
-		return nil, errors.New("Failed to decode response: " + err.Error())
+	var result OAResponseResp
+	if err := oaDecodeResponse(resp, &result); err != nil {
+		return nil, err
 	}
 
-	return &respDataDallE, nil
+	return &result, nil
 }
 
-func (c *openaiAPI) OpenAITextToSpeech(req_body *OAReqTextToSpeech) (*OATextToSpeechResp, error) {
+func (c *openaiAPI) OpenAIGetResponseOutputText(req_body *OAResponseReq) (string, error) {
+	return c.OpenAIGetResponseOutputTextContext(context.Background(), req_body)
+}
 
-	// ----------- input checker request
-	if req_body.Model == "" || (req_body.Model != "tts-1" && req_body.Model != "tts-1-hd") {
-		return nil, errors.New("Model must be gpt-3 or davinci")
+func (c *openaiAPI) OpenAIGetResponseOutputTextContext(ctx context.Context, req_body *OAResponseReq) (string, error) {
+	resp, err := c.OpenAICreateResponseContext(ctx, req_body)
+	if err != nil {
+		return "", err
 	}
 
-	if req_body.Input == "" {
-		return nil, errors.New("Input text must be provided")
+	if resp.Error != nil {
+		return "", errors.New("response failed: " + resp.Error.Message + " code: " + resp.Error.Code)
 	}
 
-	if req_body.Voice != "" && (req_body.Voice != "alloy" && req_body.Voice != "echo" && req_body.Voice != "fable" && req_body.Voice != "onyx" && req_body.Voice != "nova" && req_body.Voice != "shimer") {
-		return nil, errors.New("Voice must be alloy, echo, fable, onyx, nova, or shimmer")
+	return resp.OutputText(), nil
+}
+
+func (c *openaiAPI) OpenAIRetrieveCompletion(id string) (*OAChatCompletionResp, error) {
+	return c.OpenAIRetrieveCompletionContext(context.Background(), id)
+}
+
+func (c *openaiAPI) OpenAIRetrieveCompletionContext(ctx context.Context, id string) (*OAChatCompletionResp, error) {
+	if id == "" {
+		return nil, errors.New("completion id must be provided")
 	}
 
-	if req_body.ResponseFormat != "" && (req_body.ResponseFormat == "mp3" && req_body.ResponseFormat == "opus" && req_body.ResponseFormat == "aac" && req_body.ResponseFormat == "flac" && req_body.ResponseFormat == "wav" && req_body.ResponseFormat == "pcm") {
-		return nil, errors.New("ResponseFormat must be mp3, opus, aac, flac, wav, or pcm")
+	// stored completion is on the chat completions endpoint with the id as path
+	var result OAChatCompletionResp
+	if err := c.sendGetRequest(ctx, c.config.openAIBaseUrl+"/"+url.PathEscape(id), &result); err != nil {
+		return nil, err
 	}
 
-	if req_body.Speed != nil && (*req_body.Speed < 0.25 || *req_body.Speed > 4.0) {
-		return nil, errors.New("Speed must be between 0.25 and 4.0")
+	return &result, nil
+}
+
+func (c *openaiAPI) OpenAIListCompletions(req_query *OAListCompletionsReq) (*OAChatCompletionListResp, error) {
+	return c.OpenAIListCompletionsContext(context.Background(), req_query)
+}
+
+func (c *openaiAPI) OpenAIListCompletionsContext(ctx context.Context, req_query *OAListCompletionsReq) (*OAChatCompletionListResp, error) {
+
+	query := url.Values{}
+	if req_query != nil {
+		if req_query.Order != "" && req_query.Order != "asc" && req_query.Order != "desc" {
+			return nil, errors.New("Order must be asc or desc")
+		}
+
+		if req_query.Limit < 0 {
+			return nil, errors.New("Limit must be positive")
+		}
+
+		if req_query.After != "" {
+			query.Set("after", req_query.After)
+		}
+		if req_query.Limit > 0 {
+			query.Set("limit", strconv.Itoa(req_query.Limit))
+		}
+		if req_query.Order != "" {
+			query.Set("order", req_query.Order)
+		}
+		if req_query.Model != "" {
+			query.Set("model", req_query.Model)
+		}
+		for key, value := range req_query.Metadata {
+			query.Set("metadata["+key+"]", value)
+		}
 	}
 
-	apiKey := c.apiKey
-	if apiKey == "" {
-		return nil, errors.New("API Key is empty")
+	reqUrl := c.config.openAIBaseUrl
+	if len(query) > 0 {
+		reqUrl += "?" + query.Encode()
 	}
 
-	// create json ver for req body
-	reqBodyJson, err := json.Marshal(req_body)
-	if err != nil {
-		return nil, errors.New("Failed to marshal request body")
+	var result OAChatCompletionListResp
+	if err := c.sendGetRequest(ctx, reqUrl, &result); err != nil {
+		return nil, err
 	}
 
-	// create req
-	req, err := http.NewRequest(http.MethodPost, OAUrlTextToSpeech, bytes.NewBuffer(reqBodyJson))
+	return &result, nil
+}
+
+// sendGetRequest send GET request to OpenAI and decode the JSON response to result
+func (c *openaiAPI) sendGetRequest(ctx context.Context, reqUrl string, result interface{}) error {
+	if c.apiKey == "" {
+		return errors.New("API Key is empty")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqUrl, nil)
 	if err != nil {
-		return nil, errors.New("Failed to create request")
+		return errors.New("Failed to create request")
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+apiKey)
+	req.Header.Set("Accept", "application/json")
+	c.setRequestHeaders(req)
 
-	client := c.config.httpClient
-
-	resp, err := client.Do(req)
+	resp, err := c.sendRequest(req)
 	if err != nil {
-		return nil, errors.New("Failed to send request: " + err.Error())
+		return fmt.Errorf("Failed to send request: %w", err)
 	}
 	defer func() {
 		if resp.StatusCode != http.StatusOK {
@@ -748,29 +4086,132 @@ func (c *openaiAPI) OpenAITextToSpeech(req_body *OAReqTextToSpeech) (*OATextToSp
 	}()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, errors.New("Failed to send request: " + resp.Status)
+		return oaNewAPIError(resp, c.config.clock.Now())
 	}
 
-	// decode file mp3 response to encode base64
-	// because from the docs will be return file extension for audio, so for the response will be base64 encoded version of the audio we received
-	var b64audio, fileExt string
-	fileBytes, err := io.ReadAll(resp.Body)
+	if err := oaDecodeResponse(resp, result); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (c *openaiAPI) OpenAIResolveModel(ctx context.Context, alias string) (string, error) {
+	if alias == "" {
+		return "", errors.New("alias must be provided")
+	}
+
+	var models OAModelListResp
+	if err := c.OpenAIDoJSON(ctx, http.MethodGet, "/models", nil, &models); err != nil {
+		return "", err
+	}
+
+	ids := make([]string, 0, len(models.Data))
+	for _, model := range models.Data {
+		ids = append(ids, model.ID)
+	}
+
+	resolved, ok := oaResolveModelAlias(alias, ids)
+	if !ok {
+		return "", errors.New("model " + alias + " is not found on the available models")
+	}
+
+	return resolved, nil
+}
+
+func (c *openaiAPI) OpenAIEffectiveTimeout(ctx context.Context, model string) (time.Duration, bool) {
+	if model == "" {
+		model = c.config.openAIModel
+	}
+
+	// one attempt for the request model and one for each different fallback model,
+	// with WithContextTrimming the whole chain can be sent again after the oldest messages are dropped
+	attempts := len(c.chatModels(model))
+	if c.config.trimOnContextLength {
+		attempts *= 2
+	}
+
+	// each model is sent 1 + maxRetries times with the max backoff wait between the attempts
+	var retryWait time.Duration
+	for attempt := 1; attempt <= c.config.maxRetries; attempt++ {
+		retryWait += c.maxRetryDelay(attempt)
+	}
+
+	maxTime := (c.config.httpClient.Timeout*time.Duration(1+c.config.maxRetries) + retryWait) * time.Duration(attempts)
+	bounded := c.config.httpClient.Timeout > 0
+
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := deadline.Sub(c.config.clock.Now())
+		if remaining < 0 {
+			remaining = 0
+		}
+
+		if !bounded || remaining < maxTime {
+			maxTime = remaining
+		}
+		bounded = true
+	}
+
+	if !bounded {
+		return 0, false
+	}
+
+	return maxTime, true
+}
+
+func (c *openaiAPI) OpenAIVerifyImageURL(ctx context.Context, imageUrl string) error {
+	parsedUrl, err := url.Parse(imageUrl)
+	if err != nil || (parsedUrl.Scheme != "http" && parsedUrl.Scheme != "https") || parsedUrl.Host == "" {
+		return errors.New("imageUrl must be a valid http or https URL")
+	}
+
+	resp, err := c.headImageURL(ctx, http.MethodHead, imageUrl)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp, err = c.headImageURL(ctx, http.MethodGet, imageUrl)
+	}
 	if err != nil {
-		return nil, errors.New("Failed to read response body3: " + err.Error())
+		return fmt.Errorf("image URL is not reachable: %w", err)
 	}
 
-	b64audio = base64.StdEncoding.EncodeToString(fileBytes)
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("image URL response with status " + resp.Status)
+	}
 
-	if req_body.ResponseFormat == "" {
-		fileExt = ".mp3"
-	} else {
-		fileExt = "." + req_body.ResponseFormat
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return errors.New("image URL response has no valid content type")
 	}
 
-	result := OATextToSpeechResp{
-		B64JSON:     b64audio,
-		FormatAudio: fileExt,
+	if !oaIsSupportedImageType(mediaType) {
+		return errors.New("image URL content type " + mediaType + " is not supported, must be image/png, image/jpeg, image/gif, or image/webp")
 	}
 
-	return &result, nil
+	return nil
+}
+
+// headImageURL send the image check request without the OpenAI headers (API key must not be sent to other host), only the header is used
+func (c *openaiAPI) headImageURL(ctx context.Context, method string, imageUrl string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, imageUrl, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.config.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	return resp, nil
+}
+
+func (c *openaiAPI) LastRequestBody() []byte {
+	c.lastRequestMu.Lock()
+	defer c.lastRequestMu.Unlock()
+
+	if c.lastRequestBody == nil {
+		return nil
+	}
+
+	return append([]byte(nil), c.lastRequestBody...)
 }
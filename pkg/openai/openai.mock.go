@@ -0,0 +1,77 @@
+package openai
+
+// OAMockContentResponse creates a chat completion response with one assistant message, for testing code that use
+// OpenAISendMessage without calling the API (e.g. return it from a fake OpenAI interface implementation).
+//
+// The response has the same shape as the decoded API response: one choice with finish reason "stop" and usage filled.
+//
+// Example usage:
+//
+//	resp := OAMockContentResponse("The weather in Jakarta is sunny")
+//	content, _ := resp.Content()
+func OAMockContentResponse(text string) *OAChatCompletionResp {
+	resp := oaMockResponse(OAMessage{
+		Role:    "assistant",
+		Content: text,
+	}, "stop")
+
+	// rough token count (4 characters per token) so code reading the usage get a non zero value
+	resp.Usage.CompletionTokens = len(text)/4 + 1
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+
+	return resp
+}
+
+// OAMockToolCallResponse creates a chat completion response where the model calls one function, for scripting
+// multi-step agent tests without the API. The response has finish reason "tool_calls" and the tool call ID "call_mock_<name>".
+//
+// Parameters:
+//   - name (string): The function name, same as the tool name from OABuildTool.
+//   - argsJSON (string): The function arguments as JSON object string, e.g. `{"city":"Jakarta"}`. It is used as it is,
+//     so an invalid JSON can be used to test the handling of bad arguments from the model.
+//
+// Example usage:
+//
+//	resp := OAMockToolCallResponse("get_weather", `{"city":"Jakarta"}`)
+//	call := resp.Choices[0].Message.ToolCalls[0]
+func OAMockToolCallResponse(name string, argsJSON string) *OAChatCompletionResp {
+
+	resp := oaMockResponse(OAMessage{
+		Role: "assistant",
+		ToolCalls: []OAToolCall{
+			{
+				ID:   "call_mock_" + name,
+				Type: "function",
+				Function: OAToolCallFunction{
+					Name:      name,
+					Arguments: argsJSON,
+				},
+			},
+		},
+	}, "tool_calls")
+
+	resp.Usage.CompletionTokens = len(argsJSON)/4 + 1
+	resp.Usage.TotalTokens = resp.Usage.PromptTokens + resp.Usage.CompletionTokens
+
+	return resp
+}
+
+// oaMockResponse create the common response fields of the mock response
+func oaMockResponse(message OAMessage, finishReason string) *OAChatCompletionResp {
+	return &OAChatCompletionResp{
+		ID:      "chatcmpl-mock",
+		Object:  "chat.completion",
+		Created: 1700000000,
+		Model:   "gpt-4o-mini",
+		Choices: []OAChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: finishReason,
+			},
+		},
+		Usage: OAUsage{
+			PromptTokens: 10,
+		},
+	}
+}
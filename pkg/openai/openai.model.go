@@ -0,0 +1,132 @@
+package openai
+
+import (
+	"regexp"
+	"strings"
+)
+
+// model capability name used on OAModelSupports
+const (
+	OACapabilityVision           = "vision"            // image input
+	OACapabilityAudioIn          = "audio_in"          // audio input (input_audio content)
+	OACapabilityAudioOut         = "audio_out"         // audio output (modalities audio)
+	OACapabilityTools            = "tools"             // function calling
+	OACapabilityStructuredOutput = "structured_output" // response format json_schema
+	OACapabilityReasoning        = "reasoning"         // reasoning model (reasoning effort, max_completion_tokens)
+)
+
+// capability table by model name prefix, the longest matching prefix is used so specific model (e.g. "gpt-4o-mini") is checked
+// before the family (e.g. "gpt-4o"). dated model version (e.g. "gpt-4o-mini-2024-07-18") use the capability of the model alias.
+// keep this table updated when OpenAI release new model
+var oaModelCapabilities = map[string][]string{
+	"gpt-5":                      {OACapabilityVision, OACapabilityTools, OACapabilityStructuredOutput, OACapabilityReasoning},
+	"gpt-4.1":                    {OACapabilityVision, OACapabilityTools, OACapabilityStructuredOutput},
+	"gpt-4o":                     {OACapabilityVision, OACapabilityTools, OACapabilityStructuredOutput},
+	"gpt-4o-2024-05-13":          {OACapabilityVision, OACapabilityTools},
+	"gpt-4o-mini":                {OACapabilityVision, OACapabilityTools, OACapabilityStructuredOutput},
+	"gpt-4o-audio-preview":       {OACapabilityAudioIn, OACapabilityAudioOut, OACapabilityTools},
+	"gpt-4o-mini-audio-preview":  {OACapabilityAudioIn, OACapabilityAudioOut, OACapabilityTools},
+	"gpt-4o-search-preview":      {OACapabilityStructuredOutput},
+	"gpt-4o-mini-search-preview": {OACapabilityStructuredOutput},
+	"gpt-4-turbo":                {OACapabilityVision, OACapabilityTools},
+	"gpt-4":                      {OACapabilityTools},
+	"gpt-3.5-turbo":              {OACapabilityTools},
+	"o1":                         {OACapabilityVision, OACapabilityTools, OACapabilityStructuredOutput, OACapabilityReasoning},
+	"o1-mini":                    {OACapabilityReasoning},
+	"o1-preview":                 {OACapabilityReasoning},
+	"o3":                         {OACapabilityVision, OACapabilityTools, OACapabilityStructuredOutput, OACapabilityReasoning},
+	"o3-mini":                    {OACapabilityTools, OACapabilityStructuredOutput, OACapabilityReasoning},
+	"o4-mini":                    {OACapabilityVision, OACapabilityTools, OACapabilityStructuredOutput, OACapabilityReasoning},
+}
+
+// OAModelSupports reports whether a model supports a capability, based on the capability table maintained on this package.
+//
+// This can be used to branch the logic before sending the request, e.g. only send image to a model that supports vision,
+// instead of learning it from the API error.
+//
+// Parameters:
+//   - model (string): The model name, alias (e.g. "gpt-4o"), dated version (e.g. "gpt-4o-2024-08-06"), or fine tuned model ("ft:gpt-4o-mini:...").
+//   - capability (string): One of `OACapabilityVision` ("vision"), `OACapabilityAudioIn` ("audio_in"), `OACapabilityAudioOut` ("audio_out"),
+//     `OACapabilityTools` ("tools"), `OACapabilityStructuredOutput` ("structured_output"), or `OACapabilityReasoning` ("reasoning").
+//
+// Returns:
+//   - true if the model supports the capability, false if not or if the model is not known on the table.
+//
+// Example usage:
+//
+//	if OAModelSupports("gpt-4o-mini", OACapabilityVision) {
+//	    // send the image directly to the model
+//	} else {
+//	    // fallback to OCR
+//	}
+func OAModelSupports(model string, capability string) bool {
+	matchedPrefix := oaModelCapabilityPrefix(model)
+	if matchedPrefix == "" {
+		return false
+	}
+
+	for _, supported := range oaModelCapabilities[matchedPrefix] {
+		if supported == capability {
+			return true
+		}
+	}
+
+	return false
+}
+
+// oaModelCapabilityPrefix return the capability table prefix of the model, empty if the model is not known on the table
+func oaModelCapabilityPrefix(model string) string {
+	model = strings.TrimPrefix(model, "ft:")
+
+	matchedPrefix := ""
+	for prefix := range oaModelCapabilities {
+		if len(prefix) <= len(matchedPrefix) || !strings.HasPrefix(model, prefix) {
+			continue
+		}
+
+		// prefix must match the whole model name or followed by "-" (version or variant) or ":" (fine tuned suffix), so "o1" does not match "o10"
+		if len(model) == len(prefix) || model[len(prefix)] == '-' || model[len(prefix)] == ':' {
+			matchedPrefix = prefix
+		}
+	}
+
+	return matchedPrefix
+}
+
+// dated version suffix of model id, "2024-08-06" for current model and "0613" for older model
+var oaModelVersionRegex = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}|\d{4})$`)
+
+// oaResolveModelAlias find the newest dated version of the alias from the available model ids.
+// the alias itself is returned if it has no dated version but exist, false if the alias is not found
+func oaResolveModelAlias(alias string, ids []string) (string, bool) {
+	var resolved, resolvedKey string
+	aliasExist := false
+
+	for _, id := range ids {
+		if id == alias {
+			aliasExist = true
+			continue
+		}
+
+		version, ok := strings.CutPrefix(id, alias+"-")
+		if !ok || !oaModelVersionRegex.MatchString(version) {
+			continue
+		}
+
+		// "0613" style version is older than "YYYY-MM-DD" style version, so it is sorted before it
+		key := version
+		if len(version) == 4 {
+			key = "0" + version
+		}
+
+		if key > resolvedKey {
+			resolved, resolvedKey = id, key
+		}
+	}
+
+	if resolved != "" {
+		return resolved, true
+	}
+
+	return alias, aliasExist
+}
@@ -0,0 +1,91 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// default moderation model, the omni model support text and image input and has more categories than the legacy text models
+const oaDefaultModerationModel = "omni-moderation-latest"
+
+func (c *openaiAPI) OpenAIModerate(req_body *OAModerationReq) (*OAModerationResp, error) {
+	return c.OpenAIModerateContext(context.Background(), req_body)
+}
+
+func (c *openaiAPI) OpenAIModerateContext(ctx context.Context, req_body *OAModerationReq) (*OAModerationResp, error) {
+
+	// ----------- input checker request
+	if req_body == nil || req_body.Input == nil {
+		return nil, errors.New("Input must be provided")
+	}
+
+	switch input := req_body.Input.(type) {
+	case string:
+		if strings.TrimSpace(input) == "" {
+			return nil, errors.New("Input must be provided")
+		}
+	case []string:
+		if len(input) == 0 {
+			return nil, errors.New("Input must have at least one text")
+		}
+		for i, text := range input {
+			if strings.TrimSpace(text) == "" {
+				return nil, fmt.Errorf("Input text at index %d is empty", i)
+			}
+		}
+	default:
+		return nil, errors.New("Input must be string or []string")
+	}
+
+	if c.apiKey == "" {
+		return nil, errors.New("API Key is empty")
+	}
+
+	// copy the request so the default model is not set on the caller struct
+	reqData := *req_body
+	if reqData.Model == "" {
+		reqData.Model = oaDefaultModerationModel
+	}
+
+	reqBodyJson, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, errors.New("Failed to marshal request body")
+	}
+
+	req, err := http.NewRequestWithContext(oaModelContext(ctx, reqData.Model), http.MethodPost, OAUrlModerations, bytes.NewBuffer(reqBodyJson))
+	if err != nil {
+		return nil, errors.New("Failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	c.setRequestHeaders(req)
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send request: %w", err)
+	}
+	defer func() {
+		if resp.StatusCode != http.StatusOK {
+			io.ReadAll(resp.Body)
+		}
+		resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, oaNewAPIError(resp, c.config.clock.Now())
+	}
+
+	var result OAModerationResp
+	if err := oaDecodeResponse(resp, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
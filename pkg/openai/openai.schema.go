@@ -0,0 +1,433 @@
+package openai
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// tool function name rule from OpenAI docs
+var oaToolNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// OABuildTool builds a function tool definition with the parameters JSON schema generated from a Go struct.
+//
+// The struct fields are reflected to JSON schema so the tool parameters always follow the struct used to decode the tool call arguments.
+//
+// Field mapping:
+//   - Property name from the `json` tag (field name if no tag), field with `json:"-"` and unexported field are skipped.
+//   - Go type to JSON schema type: string -> "string", bool -> "boolean", int/uint -> "integer", float -> "number",
+//     slice/array -> "array", struct/map -> "object", time.Time -> "string" (format date-time), []byte -> "string" (base64 like encoding/json).
+//     Pointer use the element type.
+//   - Field is required unless the json tag has `omitempty` or the field is a pointer.
+//   - `description:"..."` tag is used as the property description.
+//   - `enum:"a,b,c"` tag is used as the allowed values of the property.
+//
+// Parameters:
+//   - name (string): The function name, a-z, A-Z, 0-9, underscore, and dash, max 64 characters.
+//   - description (string): Description of what the function does, used by the model to choose when to call it.
+//   - paramsStruct (interface{}): A struct value or pointer to struct describing the function arguments.
+//
+// Returns:
+//
+//	(OATool, error): The tool definition with type "function". An error is returned if the name is invalid,
+//	paramsStruct is not a struct, or a field type can not be mapped (e.g. channel, function, or recursive struct).
+//
+// Example usage:
+//
+//	type WeatherParams struct {
+//	    City string `json:"city" description:"City name, e.g. Jakarta"`
+//	    Unit string `json:"unit,omitempty" enum:"celsius,fahrenheit"`
+//	}
+//
+//	tool, err := OABuildTool("get_weather", "Get the current weather of a city", WeatherParams{})
+//	if err != nil {
+//	    log.Fatalf("Failed to build tool: %v", err)
+//	}
+//
+// References:
+//   - Function calling: https://platform.openai.com/docs/guides/function-calling
+func OABuildTool(name string, description string, paramsStruct interface{}) (OATool, error) {
+	if !oaToolNameRegex.MatchString(name) {
+		return OATool{}, errors.New("tool name must be 1-64 characters of a-z, A-Z, 0-9, underscore, or dash")
+	}
+
+	schema, err := oaSchemaFromValue(paramsStruct, false)
+	if err != nil {
+		return OATool{}, err
+	}
+
+	return OATool{
+		Type: "function",
+		Function: OAFunctionDef{
+			Name:        name,
+			Description: description,
+			Parameters:  schema,
+		},
+	}, nil
+}
+
+// OAAppendToolResult appends the result of a function call as tool message (role "tool" with the call ID) to the conversation.
+//
+// After the model answer with tool calls, the assistant message (OAMessage.MessageReq) must be added to the conversation first,
+// then one tool result message for each call, and the conversation is sent again so the model can answer with the results.
+//
+// Parameters:
+//   - messages ([]OAMessageReq): The conversation messages.
+//   - toolCallID (string): The ID of the call answered (OAToolCall.ID).
+//   - result (string): The function result, usually JSON.
+//
+// Returns:
+//
+//	[]OAMessageReq: The messages with the tool result message at the end, like append the caller slice can be reused.
+//
+// Example usage:
+//
+//	message := resp.Choices[0].Message
+//	messages = append(messages, message.MessageReq())
+//	for _, call := range message.ToolCalls {
+//	    result := runTool(call.Function.Name, call.Function.Arguments)
+//	    messages = OAAppendToolResult(messages, call.ID, result)
+//	}
+//	resp, err = client.OpenAISendMessage(&messages, false, nil, true, &OAReqBodyMessageCompletion{Messages: &messages, Tools: tools})
+//
+// References:
+//   - Function calling: https://platform.openai.com/docs/guides/function-calling
+func OAAppendToolResult(messages []OAMessageReq, toolCallID string, result string) []OAMessageReq {
+	return append(messages, OAMessageReq{
+		Role:       "tool",
+		Content:    result,
+		ToolCallID: toolCallID,
+	})
+}
+
+// OASchemaFromStruct generates the structured output JSON schema from a Go struct, so the schema and the decode target never drift.
+//
+// The schema follows the strict mode rules: every property is required and every object has "additionalProperties": false.
+// Field mapping is the same as OABuildTool (`json`, `description`, and `enum` tags), except:
+//   - Pointer field is required but nullable (type is a union with "null"), use it for value that may not exist.
+//   - Map and interface{} field are not supported because strict mode can not describe dynamic keys or any type.
+//
+// Parameters:
+//   - v (interface{}): A struct value or pointer to struct used as the response decode target.
+//
+// Returns:
+//
+//	(string, map[string]interface{}, error): The schema name (the struct type name), the JSON schema, and an error if v is not
+//	a named struct or a field type can not be mapped.
+//
+// Example usage:
+//
+//	name, schema, err := OASchemaFromStruct(Recipe{})
+//	if err != nil {
+//	    log.Fatalf("Failed to create schema: %v", err)
+//	}
+//	format := OACreateResponseFormatStrict(name, schema)
+//
+// References:
+//   - Structured outputs supported schemas: https://platform.openai.com/docs/guides/structured-outputs#supported-schemas
+func OASchemaFromStruct(v interface{}) (string, map[string]interface{}, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return "", nil, errors.New("schema source must be a struct or pointer to struct")
+	}
+
+	// schema name follow the same rule as tool name
+	name := t.Name()
+	if !oaToolNameRegex.MatchString(name) {
+		return "", nil, errors.New("schema source must be a named struct type")
+	}
+
+	schema, err := oaSchemaFromValue(v, true)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, schema, nil
+}
+
+// oaSchemaFromValue create JSON schema of a struct value, strict mode follow the structured output rules
+// (all property required and additionalProperties false on every object)
+func oaSchemaFromValue(v interface{}, strict bool) (map[string]interface{}, error) {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("schema source must be a struct or pointer to struct")
+	}
+
+	return oaSchemaFromType(t, strict, map[reflect.Type]bool{})
+}
+
+// oaSchemaFromType map Go type to JSON schema, visiting is used to detect recursive struct
+func oaSchemaFromType(t reflect.Type, strict bool, visiting map[reflect.Type]bool) (map[string]interface{}, error) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}, nil
+	}
+
+	// encoding/json encode and decode []byte as base64 string, not as array of numbers ([N]byte array is still an array)
+	if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8 {
+		return map[string]interface{}{"type": "string"}, nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}, nil
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}, nil
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}, nil
+
+	case reflect.Slice, reflect.Array:
+		items, err := oaSchemaFromType(t.Elem(), strict, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "array", "items": items}, nil
+
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, errors.New("map key must be string, got " + t.Key().String())
+		}
+
+		// strict mode does not allow dynamic key, so the map can not be described
+		if strict {
+			return nil, errors.New("map type " + t.String() + " is not supported on strict schema")
+		}
+
+		values, err := oaSchemaFromType(t.Elem(), strict, visiting)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": values}, nil
+
+	case reflect.Interface:
+		if strict {
+			return nil, errors.New("interface type " + t.String() + " is not supported on strict schema")
+		}
+		return map[string]interface{}{}, nil
+
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, errors.New("recursive struct " + t.String() + " is not supported")
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		properties := map[string]interface{}{}
+		required := []string{}
+		if err := oaCollectStructFields(t, strict, visiting, properties, &required); err != nil {
+			return nil, err
+		}
+
+		schema := map[string]interface{}{
+			"type":       "object",
+			"properties": properties,
+			"required":   required,
+		}
+		if strict {
+			schema["additionalProperties"] = false
+		}
+		return schema, nil
+	}
+
+	return nil, errors.New("type " + t.String() + " can not be mapped to JSON schema")
+}
+
+// oaCollectStructFields add the struct fields to properties and required list, embedded struct fields are added to the parent like encoding/json
+func oaCollectStructFields(t reflect.Type, strict bool, visiting map[reflect.Type]bool, properties map[string]interface{}, required *[]string) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+
+		tagName, tagOptions, _ := strings.Cut(jsonTag, ",")
+
+		// embedded struct without json name is flattened
+		if field.Anonymous && tagName == "" {
+			embedded := field.Type
+			for embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				if err := oaCollectStructFields(embedded, strict, visiting, properties, required); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tagName != "" {
+			name = tagName
+		}
+
+		fieldSchema, err := oaSchemaFromType(field.Type, strict, visiting)
+		if err != nil {
+			return errors.New("field " + field.Name + ": " + err.Error())
+		}
+
+		if description := field.Tag.Get("description"); description != "" {
+			fieldSchema["description"] = description
+		}
+
+		if enum := field.Tag.Get("enum"); enum != "" {
+			var values []interface{}
+			for _, value := range strings.Split(enum, ",") {
+				values = append(values, strings.TrimSpace(value))
+			}
+			fieldSchema["enum"] = values
+		}
+
+		// strict mode has no optional property, pointer field is expressed as nullable instead
+		if strict && field.Type.Kind() == reflect.Ptr {
+			if schemaType, ok := fieldSchema["type"].(string); ok {
+				fieldSchema["type"] = []interface{}{schemaType, "null"}
+			}
+		}
+
+		optional := strings.Contains(","+tagOptions+",", ",omitempty,") || field.Type.Kind() == reflect.Ptr
+		if strict || !optional {
+			*required = append(*required, name)
+		}
+
+		properties[name] = fieldSchema
+	}
+
+	return nil
+}
+
+// OAVerifyNoMissing checks the decoded structured output has no required field left at the zero value.
+//
+// Without strict mode the model can silently drop a field and the JSON decode still succeeds with the zero value,
+// use this function after OAUnmarshalContent to catch it.
+//
+// Parameters:
+//   - v (interface{}): The decoded struct value or pointer to struct.
+//   - required ([]string): The JSON names of the fields that must not be empty. If nil, every exported field
+//     without `omitempty` on the json tag is required, pointer field included. This is not the OABuildTool rule
+//     (pointer field is optional there), a nil pointer here means the model dropped the field.
+//
+// Returns:
+//   - error: nil if all required fields have a value, otherwise an error listing the empty fields. An error is also returned
+//     if v is not a struct or a required name is not a field of the struct.
+//
+// Notes:
+//   - The check is on the top level fields and uses the Go zero value, so a legitimate false, 0, or "" is reported as missing.
+//     Use pointer fields for value where zero is valid, a pointer is only empty when the field is missing from the JSON.
+//
+// Example usage:
+//
+//	var recipe Recipe
+//	if err := OAUnmarshalContent(resp, &recipe); err != nil {
+//	    log.Fatalf("Failed to decode recipe: %v", err)
+//	}
+//	if err := OAVerifyNoMissing(recipe, nil); err != nil {
+//	    log.Printf("model dropped fields: %v", err)
+//	}
+func OAVerifyNoMissing(v interface{}, required []string) error {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return errors.New("value must not be nil")
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return errors.New("value must be a struct or pointer to struct")
+	}
+
+	// json name to field value, and the default required list from the tags
+	fields := map[string]reflect.Value{}
+	var defaultRequired []string
+	oaCollectFieldValues(value, fields, &defaultRequired)
+
+	if required == nil {
+		required = defaultRequired
+	}
+
+	var missing []string
+	for _, name := range required {
+		field, ok := fields[name]
+		if !ok {
+			return errors.New("required field " + name + " is not a field of " + value.Type().String())
+		}
+
+		if field.IsZero() {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.New("required fields are empty: " + strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// oaCollectFieldValues collect the struct field values by json name, embedded struct fields are added to the parent like encoding/json
+func oaCollectFieldValues(value reflect.Value, fields map[string]reflect.Value, required *[]string) {
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		jsonTag := field.Tag.Get("json")
+		if jsonTag == "-" {
+			continue
+		}
+
+		tagName, tagOptions, _ := strings.Cut(jsonTag, ",")
+
+		if field.Anonymous && tagName == "" {
+			embedded := value.Field(i)
+			for embedded.Kind() == reflect.Ptr && !embedded.IsNil() {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				oaCollectFieldValues(embedded, fields, required)
+				continue
+			}
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		name := field.Name
+		if tagName != "" {
+			name = tagName
+		}
+
+		fields[name] = value.Field(i)
+		if !strings.Contains(","+tagOptions+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
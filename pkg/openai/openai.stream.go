@@ -0,0 +1,440 @@
+package openai
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// max size of one server-sent event line, a chunk is small but the buffer must fit the largest delta (e.g. long tool arguments)
+const oaStreamMaxLineSize = 1024 * 1024
+
+func (c *openaiAPI) OpenAISendMessageStream(ctx context.Context, content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (<-chan OAChatCompletionChunk, error) {
+	reqData, limit, err := c.buildChatRequestBody(content, with_format_response, format_response, with_custom_reqbody, req_body_custom, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.startChatStream(ctx, &reqData, limit)
+}
+
+func (c *openaiAPI) OpenAISendMessageStreamTo(ctx context.Context, w io.Writer, content *[]OAMessageReq, with_format_response bool, format_response *map[string]interface{}, with_custom_reqbody bool, req_body_custom *OAReqBodyMessageCompletion, params ...OASamplingParams) (*OAUsage, error) {
+	if w == nil {
+		return nil, errors.New("writer must be provided")
+	}
+
+	reqData, limit, err := c.buildChatRequestBody(content, with_format_response, format_response, with_custom_reqbody, req_body_custom, params)
+	if err != nil {
+		return nil, err
+	}
+
+	// tool calls are streamed as delta fragments that can not be written as text, they would be lost
+	if len(reqData.Tools) > 0 {
+		return nil, errors.New("OpenAISendMessageStreamTo does not support Tools, use OpenAISendMessageStream with OACollectStream to get the tool calls")
+	}
+
+	// usage is only sent on the last chunk when include_usage is set
+	reqData.StreamOptions = &OAStreamOptions{IncludeUsage: true}
+
+	// stop the stream when the writer fail, so the reader goroutine does not keep reading the body
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := c.startChatStream(ctx, &reqData, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var usage *OAUsage
+	var refusal strings.Builder
+	for chunk := range stream {
+		if chunk.Err != nil {
+			return usage, chunk.Err
+		}
+
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+
+		// refusal is streamed on delta.refusal, it is not written as content
+		refusal.WriteString(chunk.Refusal())
+
+		if text := chunk.Content(); text != "" {
+			if _, err := io.WriteString(w, text); err != nil {
+				return usage, fmt.Errorf("Failed to write stream content: %w", err)
+			}
+		}
+	}
+
+	// channel closed without [DONE] because ctx is done
+	if err := ctx.Err(); err != nil {
+		return usage, err
+	}
+
+	if refusal.Len() > 0 {
+		return usage, errors.New("model refused the request: " + refusal.String())
+	}
+
+	return usage, nil
+}
+
+// OACollectStream reads the chat stream until the end and assembles the chunks into one chat completion response,
+// like the response of OpenAISendMessage. The content and refusal delta of each choice are joined separately,
+// so a refused streamed response has the refusal text on `Message.Refusal` instead of an empty content.
+// Tool call fragments are joined by the call index into `Message.ToolCalls` with the complete arguments JSON,
+// and the logprobs of each delta are appended on `Logprobs`.
+//
+// Parameters:
+//   - stream (<-chan OAChatCompletionChunk): The channel from OpenAISendMessageStream (or one output of OATeeStream).
+//
+// Returns:
+//
+//	(*OAChatCompletionResp, error): The assembled response with the usage if the stream has a usage chunk.
+//	The error of the error chunk is returned together with the response assembled before the error.
+//
+// Example usage:
+//
+//	stream, err := client.OpenAISendMessageStream(ctx, &content, false, nil, false, nil)
+//	if err != nil {
+//	    log.Fatalf("Failed to start stream: %v", err)
+//	}
+//
+//	resp, err := OACollectStream(stream)
+//	if err != nil {
+//	    log.Fatalf("Stream failed: %v", err)
+//	}
+//	if refusal := resp.Choices[0].Message.Refusal; refusal != "" {
+//	    log.Printf("model refused: %s", refusal)
+//	}
+func OACollectStream(stream <-chan OAChatCompletionChunk) (*OAChatCompletionResp, error) {
+	result := &OAChatCompletionResp{Object: "chat.completion"}
+	var builders []*oaChoiceBuilder
+
+	for chunk := range stream {
+		if chunk.Err != nil {
+			oaFinishCollectedChoices(result, builders)
+			return result, chunk.Err
+		}
+
+		if result.ID == "" {
+			result.ID = chunk.ID
+			result.Created = chunk.Created
+			result.Model = chunk.Model
+			result.SystemFingerprint = chunk.SystemFingerprint
+		}
+
+		if chunk.Usage != nil {
+			result.Usage = *chunk.Usage
+		}
+
+		for _, choice := range chunk.Choices {
+			if choice.Index < 0 {
+				continue
+			}
+
+			for len(result.Choices) <= choice.Index {
+				result.Choices = append(result.Choices, OAChoice{Index: len(result.Choices)})
+				builders = append(builders, &oaChoiceBuilder{})
+			}
+
+			collected := &result.Choices[choice.Index]
+			if choice.Delta.Role != "" {
+				collected.Message.Role = choice.Delta.Role
+			}
+			if choice.FinishReason != "" {
+				collected.FinishReason = choice.FinishReason
+			}
+
+			builder := builders[choice.Index]
+			builder.content.WriteString(choice.Delta.Content)
+			builder.refusal.WriteString(choice.Delta.Refusal)
+			builder.addToolCalls(choice.Delta.ToolCalls)
+
+			// each delta carry the logprobs of its own tokens, appended in order they are the logprobs of the whole message
+			if choice.Logprobs != nil {
+				if collected.Logprobs == nil {
+					collected.Logprobs = &OALogprobs{}
+				}
+				collected.Logprobs.Content = append(collected.Logprobs.Content, choice.Logprobs.Content...)
+				collected.Logprobs.Refusal = append(collected.Logprobs.Refusal, choice.Logprobs.Refusal...)
+			}
+		}
+	}
+
+	oaFinishCollectedChoices(result, builders)
+
+	return result, nil
+}
+
+// oaChoiceBuilder join the delta of one choice, the tool calls is ordered by the call index of the delta
+type oaChoiceBuilder struct {
+	content   strings.Builder
+	refusal   strings.Builder
+	toolCalls []OAToolCall
+	toolArgs  []*strings.Builder
+}
+
+// addToolCalls add the tool call fragments, ID, type, and name are set once and the arguments fragments are joined
+func (b *oaChoiceBuilder) addToolCalls(deltas []OAToolCallDelta) {
+	for _, delta := range deltas {
+		if delta.Index < 0 {
+			continue
+		}
+
+		for len(b.toolCalls) <= delta.Index {
+			b.toolCalls = append(b.toolCalls, OAToolCall{})
+			b.toolArgs = append(b.toolArgs, &strings.Builder{})
+		}
+
+		call := &b.toolCalls[delta.Index]
+		if delta.ID != "" {
+			call.ID = delta.ID
+		}
+		if delta.Type != "" {
+			call.Type = delta.Type
+		}
+		if delta.Function.Name != "" {
+			call.Function.Name = delta.Function.Name
+		}
+		b.toolArgs[delta.Index].WriteString(delta.Function.Arguments)
+	}
+}
+
+// oaFinishCollectedChoices set the joined content, refusal, and tool calls on the collected choices
+func oaFinishCollectedChoices(result *OAChatCompletionResp, builders []*oaChoiceBuilder) {
+	for i, builder := range builders {
+		message := &result.Choices[i].Message
+		message.Content = builder.content.String()
+		message.Refusal = builder.refusal.String()
+
+		if len(builder.toolCalls) > 0 {
+			message.ToolCalls = make([]OAToolCall, len(builder.toolCalls))
+			for j, call := range builder.toolCalls {
+				call.Function.Arguments = builder.toolArgs[j].String()
+				message.ToolCalls[j] = call
+			}
+		}
+	}
+}
+
+// startChatStream send the chat request with stream and start the reader goroutine, the request body is adjusted for the model
+func (c *openaiAPI) startChatStream(ctx context.Context, reqData *OAReqBodyMessageCompletion, limit int) (<-chan OAChatCompletionChunk, error) {
+	oaApplyMaxTokens(reqData, limit)
+	if err := c.adaptResponseFormat(reqData); err != nil {
+		return nil, err
+	}
+	reqData.Model = c.mapModel(reqData.Model)
+	reqData.Stream = true
+
+	reqBodyJSON, err := json.Marshal(reqData)
+	if err != nil {
+		return nil, errors.New("Failed to marshal request body")
+	}
+
+	req, err := http.NewRequestWithContext(oaModelContext(ctx, reqData.Model), http.MethodPost, c.config.openAIBaseUrl, bytes.NewBuffer(reqBodyJSON))
+	if err != nil {
+		return nil, errors.New("Failed to create request")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "text/event-stream")
+	c.setRequestHeaders(req)
+
+	resp, err := c.sendRequest(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to send request: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, oaNewAPIError(resp, c.config.clock.Now())
+	}
+
+	if err := oaCheckContentType(resp, "text/event-stream"); err != nil {
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+		return nil, err
+	}
+
+	stream := make(chan OAChatCompletionChunk)
+	go c.readChatStream(ctx, resp.Body, stream)
+
+	return stream, nil
+}
+
+// readChatStream read the server-sent events from the body and send the chunks to the stream until [DONE], error, or ctx done.
+// the body is closed and the stream channel is closed when the function return
+func (c *openaiAPI) readChatStream(ctx context.Context, body io.ReadCloser, stream chan<- OAChatCompletionChunk) {
+	defer close(stream)
+	defer body.Close()
+
+	// close the body as soon as ctx is done, so a read blocked on a slow server return right away
+	// even with http transport that does not stop the body read on cancel
+	stopCloseOnDone := context.AfterFunc(ctx, func() {
+		body.Close()
+	})
+	defer stopCloseOnDone()
+
+	// send return false when the caller stopped reading (ctx done), so the goroutine can stop
+	send := func(chunk OAChatCompletionChunk) bool {
+		select {
+		case stream <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), oaStreamMaxLineSize)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// only data field is used, empty line (event separator), comment (": keep-alive"), and other fields are skipped
+		data, ok := strings.CutPrefix(line, "data:")
+		if !ok {
+			continue
+		}
+		data = strings.TrimSpace(data)
+
+		if data == "[DONE]" {
+			return
+		}
+
+		// OpenAI send error as data event when the stream fails after it started
+		var errEvent OARespError
+		if err := json.Unmarshal([]byte(data), &errEvent); err == nil && errEvent.Error.Message != "" {
+			send(OAChatCompletionChunk{Err: &OAAPIError{
+				StatusCode: http.StatusOK,
+				Type:       errEvent.Error.Type,
+				Code:       errEvent.Error.Code,
+				Message:    errEvent.Error.Message,
+				Param:      errEvent.Error.Param,
+			}})
+			return
+		}
+
+		var chunk OAChatCompletionChunk
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			send(OAChatCompletionChunk{Err: errors.New("Failed to decode stream chunk: " + err.Error())})
+			return
+		}
+
+		if chunk.Usage != nil && c.config.usageCallback != nil {
+			c.config.usageCallback(chunk.Model, *chunk.Usage)
+		}
+
+		if !send(chunk) {
+			return
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		// read error from the closed body after ctx done is the cancel itself, the caller already stopped reading
+		if ctx.Err() != nil {
+			return
+		}
+		send(OAChatCompletionChunk{Err: fmt.Errorf("Failed to read stream: %w", err)})
+		return
+	}
+
+	// body ended without [DONE], the stream is cut by the server or a proxy
+	send(OAChatCompletionChunk{Err: errors.New("stream ended before [DONE]")})
+}
+
+// OAStreamTeePolicy is what OATeeStream do when a consumer buffer is full
+type OAStreamTeePolicy int
+
+const (
+	// wait until the slow consumer read, every consumer get every chunk but all consumers go at the speed of the slowest one
+	OAStreamTeeBlock OAStreamTeePolicy = iota
+	// skip the chunk for the consumer with full buffer, the other consumers are not slowed down.
+	// error chunk is never dropped so every consumer know the stream failed. use it with buffer more than 0
+	OAStreamTeeDrop
+)
+
+// OATeeStream fans out one chat stream to n channels so the same generation can be used by many consumers (e.g. display and log).
+// each output channel has buffer size buffer, when the buffer of a consumer is full the policy decide to wait or drop the chunk.
+// all output channels are closed when the source stream is closed or ctx is done, use the same ctx as the stream request
+// so a consumer that stop reading does not keep the fan out goroutine waiting
+//
+// Example usage:
+//
+//	stream, err := client.OpenAISendMessageStream(ctx, &messages, false, nil, false, nil)
+//	if err != nil {
+//	    return err
+//	}
+//	outs := openai.OATeeStream(ctx, stream, 2, 64, openai.OAStreamTeeBlock)
+//
+//	go func() {
+//	    var full strings.Builder
+//	    for chunk := range outs[1] {
+//	        full.WriteString(chunk.Content())
+//	    }
+//	    log.Println(full.String())
+//	}()
+//
+//	for chunk := range outs[0] {
+//	    fmt.Print(chunk.Content())
+//	}
+func OATeeStream(ctx context.Context, stream <-chan OAChatCompletionChunk, n int, buffer int, policy OAStreamTeePolicy) []<-chan OAChatCompletionChunk {
+	if n < 1 {
+		n = 1
+	}
+	if buffer < 0 {
+		buffer = 0
+	}
+
+	outs := make([]chan OAChatCompletionChunk, n)
+	result := make([]<-chan OAChatCompletionChunk, n)
+	for i := range outs {
+		outs[i] = make(chan OAChatCompletionChunk, buffer)
+		result[i] = outs[i]
+	}
+
+	go func() {
+		defer func() {
+			for _, out := range outs {
+				close(out)
+			}
+		}()
+
+		for {
+			var chunk OAChatCompletionChunk
+			var ok bool
+			select {
+			case chunk, ok = <-stream:
+				if !ok {
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+
+			for _, out := range outs {
+				if policy == OAStreamTeeDrop && chunk.Err == nil {
+					select {
+					case out <- chunk:
+					default:
+					}
+					continue
+				}
+
+				select {
+				case out <- chunk:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return result
+}
@@ -0,0 +1,1632 @@
+package openai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRedirectTransport send every request to the test server, so endpoint with fixed url (image, TTS, ...) can be tested
+type testRedirectTransport struct {
+	target *url.URL
+	next   http.RoundTripper // http.DefaultTransport when nil
+}
+
+func (t testRedirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.Scheme = t.target.Scheme
+	req.URL.Host = t.target.Host
+
+	next := t.next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return next.RoundTrip(req)
+}
+
+// newTestClientOrg create client with organization and project that send all request to a test server with the handler
+func newTestClientOrg(t *testing.T, organization string, project string, handler http.HandlerFunc, opts ...ClientOption) OpenAI {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server url: %v", err)
+	}
+
+	opts = append([]ClientOption{
+		WithHTTPClient(&http.Client{Transport: testRedirectTransport{target: target}}),
+	}, opts...)
+
+	client, err := New("test-key", organization, project, opts...)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	return client
+}
+
+// newTestClient create client that send all request to a test server with the handler
+func newTestClient(t *testing.T, handler http.HandlerFunc, opts ...ClientOption) OpenAI {
+	t.Helper()
+	return newTestClientOrg(t, "", "", handler, opts...)
+}
+
+// writeTestJSON write the JSON response with the status code
+func writeTestJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestCreateImageDallEContentPolicyViolation(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error": {"message": "Your request was rejected as a result of our safety system.", "type": "invalid_request_error", "param": null, "code": "content_policy_violation"}}`))
+	})
+
+	_, err := client.OpenAICreateImageDallE(&OAReqImageGeneratorDallE{
+		Prompt: "a forbidden prompt",
+		Model:  "dall-e-3",
+	})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+
+	var apiErr *OAAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *OAAPIError, got %T: %v", err, err)
+	}
+
+	if apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, http.StatusBadRequest)
+	}
+	if apiErr.Code != "content_policy_violation" {
+		t.Errorf("Code = %q, want %q", apiErr.Code, "content_policy_violation")
+	}
+	if apiErr.Type != "invalid_request_error" {
+		t.Errorf("Type = %q, want %q", apiErr.Type, "invalid_request_error")
+	}
+	if !apiErr.IsContentPolicyViolation() {
+		t.Error("IsContentPolicyViolation() = false, want true")
+	}
+}
+
+func TestGetFirstContentDataRespNoChoices(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"id": "chatcmpl-1", "choices": []interface{}{}})
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	msg, err := client.OpenAIGetFirstContentDataResp(&messages, false, nil, false, nil)
+	if err == nil {
+		t.Fatalf("expected error for empty choices, got message %+v", msg)
+	}
+	if msg != nil {
+		t.Errorf("message = %+v, want nil", msg)
+	}
+}
+
+// writeTestStream write the server-sent events with the data lines and [DONE] at the end
+func writeTestStream(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	for _, event := range events {
+		fmt.Fprintf(w, "data: %s\n\n", event)
+	}
+	fmt.Fprint(w, "data: [DONE]\n\n")
+}
+
+func TestSendMessageStreamTo(t *testing.T) {
+	var reqBody map[string]interface{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		writeTestStream(w,
+			`{"id":"chatcmpl-1","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+			`{"id":"chatcmpl-1","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":"Hello"}}]}`,
+			`{"id":"chatcmpl-1","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":" world"},"finish_reason":"stop"}]}`,
+			`{"id":"chatcmpl-1","model":"gpt-4o-mini","choices":[],"usage":{"prompt_tokens":5,"completion_tokens":2,"total_tokens":7}}`,
+		)
+	})
+
+	var out strings.Builder
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	usage, err := client.OpenAISendMessageStreamTo(context.Background(), &out, &messages, false, nil, false, nil)
+	if err != nil {
+		t.Fatalf("OpenAISendMessageStreamTo() error = %v", err)
+	}
+
+	if out.String() != "Hello world" {
+		t.Errorf("written content = %q, want %q", out.String(), "Hello world")
+	}
+	if usage == nil || usage.TotalTokens != 7 {
+		t.Errorf("usage = %+v, want total tokens 7", usage)
+	}
+
+	if reqBody["stream"] != true {
+		t.Errorf("request stream = %v, want true", reqBody["stream"])
+	}
+	streamOptions, _ := reqBody["stream_options"].(map[string]interface{})
+	if streamOptions["include_usage"] != true {
+		t.Errorf("request stream_options = %v, want include_usage true", reqBody["stream_options"])
+	}
+}
+
+// failingWriter fail every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func TestSendMessageStreamToWriteError(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeTestStream(w, `{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"content":"Hello"}}]}`)
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	_, err := client.OpenAISendMessageStreamTo(context.Background(), failingWriter{}, &messages, false, nil, false, nil)
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("error = %v, want wrapping io.ErrClosedPipe", err)
+	}
+}
+
+// refusal fixture, the refusal is streamed on delta.refusal with null content
+var testRefusalStreamEvents = []string{
+	`{"id":"chatcmpl-2","model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"role":"assistant","content":null,"refusal":""}}]}`,
+	`{"id":"chatcmpl-2","model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"refusal":"I'm sorry, "}}]}`,
+	`{"id":"chatcmpl-2","model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{"refusal":"I can't help with that."}}]}`,
+	`{"id":"chatcmpl-2","model":"gpt-4o-2024-08-06","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+}
+
+func TestCollectStreamRefusal(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeTestStream(w, testRefusalStreamEvents...)
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "do something unsafe"}}
+	stream, err := client.OpenAISendMessageStream(context.Background(), &messages, false, nil, false, nil)
+	if err != nil {
+		t.Fatalf("OpenAISendMessageStream() error = %v", err)
+	}
+
+	resp, err := OACollectStream(stream)
+	if err != nil {
+		t.Fatalf("OACollectStream() error = %v", err)
+	}
+
+	if len(resp.Choices) != 1 {
+		t.Fatalf("choices = %d, want 1", len(resp.Choices))
+	}
+
+	message := resp.Choices[0].Message
+	if message.Refusal != "I'm sorry, I can't help with that." {
+		t.Errorf("Refusal = %q, want the joined refusal deltas", message.Refusal)
+	}
+	if message.Content != "" {
+		t.Errorf("Content = %q, want empty", message.Content)
+	}
+	if message.Role != "assistant" {
+		t.Errorf("Role = %q, want assistant", message.Role)
+	}
+	if resp.Choices[0].FinishReason != "stop" {
+		t.Errorf("FinishReason = %q, want stop", resp.Choices[0].FinishReason)
+	}
+	if resp.ID != "chatcmpl-2" {
+		t.Errorf("ID = %q, want chatcmpl-2", resp.ID)
+	}
+}
+
+func TestSendMessageStreamToRefusal(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeTestStream(w, testRefusalStreamEvents...)
+	})
+
+	var out strings.Builder
+	messages := []OAMessageReq{{Role: "user", Content: "do something unsafe"}}
+	_, err := client.OpenAISendMessageStreamTo(context.Background(), &out, &messages, false, nil, false, nil)
+	if err == nil || !strings.Contains(err.Error(), "I'm sorry, I can't help with that.") {
+		t.Fatalf("error = %v, want refusal error", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("written content = %q, want empty", out.String())
+	}
+}
+
+// fakeClock is the test clock for withClock, Sleep return right away and record the wait so retry and Retry-After can be tested without real sleep
+type fakeClock struct {
+	mu    sync.Mutex
+	now   time.Time
+	slept []time.Duration
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)}
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.now
+}
+
+func (f *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.slept = append(f.slept, d)
+	f.now = f.now.Add(d)
+
+	return nil
+}
+
+func (f *fakeClock) Slept() []time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]time.Duration(nil), f.slept...)
+}
+
+func TestRetryAfterHTTPDateUsesClientClock(t *testing.T) {
+	clock := newFakeClock()
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", clock.Now().Add(30*time.Second).Format(http.TimeFormat))
+		writeTestJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+			"error": map[string]interface{}{"message": "Rate limit reached", "type": "requests", "code": "rate_limit_exceeded"},
+		})
+	}, withClock(clock))
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	_, err := client.OpenAISendMessage(&messages, false, nil, false, nil)
+
+	var apiErr *OAAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *OAAPIError, got %T: %v", err, err)
+	}
+	if apiErr.RetryAfter != 30*time.Second {
+		t.Errorf("RetryAfter = %s, want 30s from the client clock", apiErr.RetryAfter)
+	}
+	if !apiErr.IsRateLimit() {
+		t.Error("IsRateLimit() = false, want true")
+	}
+}
+
+func TestCustomBodyResponseFormatConflict(t *testing.T) {
+	var requests int
+	var reqBody map[string]interface{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		writeTestChatContent(w, `{"answer": "ok"}`)
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	customFormat := OACreateResponseFormat("custom", map[string]interface{}{"type": "object"})
+	otherFormat := OACreateResponseFormat("other", map[string]interface{}{"type": "object"})
+
+	// both formats set: rejected before sending so the custom format is not overwritten silently
+	_, err := client.OpenAISendMessage(nil, true, &otherFormat, true, &OAReqBodyMessageCompletion{
+		Model:          "gpt-4o-mini",
+		Messages:       &messages,
+		ResponseFormat: customFormat,
+	})
+	if err == nil || !strings.Contains(err.Error(), "already has ResponseFormat") {
+		t.Fatalf("error = %v, want ResponseFormat conflict error", err)
+	}
+	if requests != 0 {
+		t.Fatalf("requests sent = %d, want 0", requests)
+	}
+
+	// only the custom format: kept as is
+	_, err = client.OpenAISendMessage(nil, false, nil, true, &OAReqBodyMessageCompletion{
+		Model:          "gpt-4o-mini",
+		Messages:       &messages,
+		ResponseFormat: customFormat,
+	})
+	if err != nil {
+		t.Fatalf("OpenAISendMessage() error = %v", err)
+	}
+	if got := testSchemaName(reqBody); got != "custom" {
+		t.Errorf("sent json_schema name = %q, want custom", got)
+	}
+
+	// only format_response on custom body without format: format_response is used
+	_, err = client.OpenAISendMessage(nil, true, &otherFormat, true, &OAReqBodyMessageCompletion{
+		Model:    "gpt-4o-mini",
+		Messages: &messages,
+	})
+	if err != nil {
+		t.Fatalf("OpenAISendMessage() error = %v", err)
+	}
+	if got := testSchemaName(reqBody); got != "other" {
+		t.Errorf("sent json_schema name = %q, want other", got)
+	}
+}
+
+// testSchemaName return the json_schema name of the response_format on the decoded request body
+func testSchemaName(reqBody map[string]interface{}) string {
+	format, _ := reqBody["response_format"].(map[string]interface{})
+	schema, _ := format["json_schema"].(map[string]interface{})
+	name, _ := schema["name"].(string)
+
+	return name
+}
+
+func TestModelMapperCustomBodyDefaultModel(t *testing.T) {
+	var sentModel string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody OAReqBodyMessageCompletion
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		sentModel = reqBody.Model
+		writeTestJSON(w, http.StatusOK, OAMockContentResponse("ok"))
+	}, WithModel("gpt-4o"), WithModelMapper(func(model string) string {
+		return "deployment-" + model
+	}))
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	_, err := client.OpenAISendMessage(nil, false, nil, true, &OAReqBodyMessageCompletion{Messages: &messages})
+	if err != nil {
+		t.Fatalf("OpenAISendMessage() error = %v", err)
+	}
+
+	if sentModel != "deployment-gpt-4o" {
+		t.Errorf("sent model = %q, want deployment-gpt-4o", sentModel)
+	}
+}
+
+// run with -race: many goroutines share one client with per-call overrides, the config and caller bodies must not be mutated
+func TestConcurrentSendMessageOverrides(t *testing.T) {
+	var mu sync.Mutex
+	sentModels := map[string]int{}
+	var usageCalls int
+
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		var reqBody OAReqBodyMessageCompletion
+		json.NewDecoder(r.Body).Decode(&reqBody)
+
+		mu.Lock()
+		sentModels[reqBody.Model]++
+		mu.Unlock()
+
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{
+			"id":      "chatcmpl-1",
+			"model":   reqBody.Model,
+			"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"role": "assistant", "content": "ok"}}},
+			"usage":   map[string]interface{}{"prompt_tokens": 5, "completion_tokens": 1, "total_tokens": 6},
+		})
+	},
+		WithSystemPrompt("You are a test assistant."),
+		WithMaxTokens(100),
+		WithCaptureLastRequest(true),
+		WithUsageCallback(func(model string, usage OAUsage) {
+			mu.Lock()
+			usageCalls++
+			mu.Unlock()
+		}),
+	)
+
+	models := []string{"gpt-4o-mini", "gpt-4o", "o3-mini", "gpt-4.1"}
+	const callsPerModel = 10
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(models)*callsPerModel)
+	for i := 0; i < len(models)*callsPerModel; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			model := models[i%len(models)]
+			messages := []OAMessageReq{{Role: "user", Content: fmt.Sprintf("hello %d", i)}}
+			body := &OAReqBodyMessageCompletion{
+				Model:     model,
+				Messages:  &messages,
+				MaxTokens: 10 + i,
+			}
+
+			resp, err := client.OpenAISendMessage(nil, false, nil, true, body)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if resp.Model != model {
+				errs <- fmt.Errorf("call %d: response model = %q, want %q", i, resp.Model, model)
+			}
+
+			// the caller body and messages must not be changed by the client adjustment (system prompt, token field)
+			if body.MaxTokens != 10+i || body.MaxCompletionTokens != 0 || len(messages) != 1 {
+				errs <- fmt.Errorf("call %d: caller body changed: %+v", i, body)
+			}
+			_ = client.LastRequestBody()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	for _, model := range models {
+		if sentModels[model] != callsPerModel {
+			t.Errorf("requests with model %q = %d, want %d", model, sentModels[model], callsPerModel)
+		}
+	}
+	if usageCalls != len(models)*callsPerModel {
+		t.Errorf("usage callback calls = %d, want %d", usageCalls, len(models)*callsPerModel)
+	}
+}
+
+// newTestTTSClient create client with TTS test server that return fake audio, the requests counter and the last request body are returned
+func newTestTTSClient(t *testing.T) (OpenAI, *int, *OAReqTextToSpeech) {
+	t.Helper()
+
+	requests := 0
+	var lastBody OAReqTextToSpeech
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		json.NewDecoder(r.Body).Decode(&lastBody)
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("fake-audio"))
+	})
+
+	return client, &requests, &lastBody
+}
+
+func TestTextToSpeechVoiceValidation(t *testing.T) {
+	tests := []struct {
+		voice   string
+		wantErr bool
+	}{
+		{voice: "alloy"},
+		{voice: "echo"},
+		{voice: "fable"},
+		{voice: "onyx"},
+		{voice: "nova"},
+		{voice: "shimmer"},
+		{voice: "shimer", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.voice, func(t *testing.T) {
+			client, requests, _ := newTestTTSClient(t)
+
+			_, err := client.OpenAITextToSpeech(&OAReqTextToSpeech{
+				Model:          "tts-1",
+				Input:          "Hello",
+				Voice:          tt.voice,
+				ResponseFormat: "mp3",
+			})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("voice %q: expected validation error, got nil", tt.voice)
+				}
+				if *requests != 0 {
+					t.Errorf("voice %q: requests sent = %d, want 0", tt.voice, *requests)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("voice %q: unexpected error: %v", tt.voice, err)
+			}
+			if *requests != 1 {
+				t.Errorf("voice %q: requests sent = %d, want 1", tt.voice, *requests)
+			}
+		})
+	}
+}
+
+func TestTextToSpeechResponseFormatValidation(t *testing.T) {
+	tests := []struct {
+		name       string
+		format     string
+		wantErr    bool
+		wantSent   string
+		wantFormat string
+	}{
+		{name: "valid", format: "opus", wantSent: "opus", wantFormat: ".opus"},
+		{name: "invalid", format: "xyz", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, requests, lastBody := newTestTTSClient(t)
+
+			resp, err := client.OpenAITextToSpeech(&OAReqTextToSpeech{
+				Model:          "tts-1",
+				Input:          "Hello",
+				Voice:          "alloy",
+				ResponseFormat: tt.format,
+			})
+
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("format %q: expected validation error, got nil", tt.format)
+				}
+				if *requests != 0 {
+					t.Errorf("format %q: requests sent = %d, want 0", tt.format, *requests)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("format %q: unexpected error: %v", tt.format, err)
+			}
+			if lastBody.ResponseFormat != tt.wantSent {
+				t.Errorf("sent response_format = %q, want %q", lastBody.ResponseFormat, tt.wantSent)
+			}
+			if resp.FormatAudio != tt.wantFormat {
+				t.Errorf("FormatAudio = %q, want %q", resp.FormatAudio, tt.wantFormat)
+			}
+
+			audio, err := resp.Bytes()
+			if err != nil || string(audio) != "fake-audio" {
+				t.Errorf("Bytes() = %q, %v, want fake-audio", audio, err)
+			}
+		})
+	}
+}
+
+func TestTextToSpeechResponseFormatDefault(t *testing.T) {
+	client, _, lastBody := newTestTTSClient(t)
+
+	resp, err := client.OpenAITextToSpeech(&OAReqTextToSpeech{
+		Model: "tts-1",
+		Input: "Hello",
+		Voice: "alloy",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if lastBody.ResponseFormat != "mp3" {
+		t.Errorf("sent response_format = %q, want mp3", lastBody.ResponseFormat)
+	}
+	if resp.FormatAudio != ".mp3" {
+		t.Errorf("FormatAudio = %q, want .mp3", resp.FormatAudio)
+	}
+}
+
+func TestOrganizationProjectHeaders(t *testing.T) {
+	tests := []struct {
+		name         string
+		organization string
+		project      string
+	}{
+		{name: "set", organization: "org-test", project: "proj-test"},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]http.Header{}
+			client := newTestClientOrg(t, tt.organization, tt.project, func(w http.ResponseWriter, r *http.Request) {
+				headers[r.URL.Path] = r.Header.Clone()
+
+				switch {
+				case strings.HasSuffix(r.URL.Path, "/chat/completions"):
+					writeTestJSON(w, http.StatusOK, map[string]interface{}{
+						"id":      "chatcmpl-1",
+						"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"role": "assistant", "content": "hi"}}},
+					})
+				case strings.HasSuffix(r.URL.Path, "/images/generations"):
+					writeTestJSON(w, http.StatusOK, map[string]interface{}{
+						"created": 1,
+						"data":    []interface{}{map[string]interface{}{"url": "https://example.com/image.png"}},
+					})
+				default:
+					w.Header().Set("Content-Type", "audio/mpeg")
+					w.Write([]byte("fake-audio"))
+				}
+			})
+
+			messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+			if _, err := client.OpenAISendMessage(&messages, false, nil, false, nil); err != nil {
+				t.Fatalf("chat: unexpected error: %v", err)
+			}
+			if _, err := client.OpenAICreateImageDallE(&OAReqImageGeneratorDallE{Prompt: "a cat", Model: "dall-e-3"}); err != nil {
+				t.Fatalf("image: unexpected error: %v", err)
+			}
+			if _, err := client.OpenAITextToSpeech(&OAReqTextToSpeech{Model: "tts-1", Input: "Hello", Voice: "alloy"}); err != nil {
+				t.Fatalf("tts: unexpected error: %v", err)
+			}
+
+			if len(headers) != 3 {
+				t.Fatalf("requests received on %d paths, want 3", len(headers))
+			}
+			for path, h := range headers {
+				if _, ok := h["Openai-Organization"]; ok != (tt.organization != "") || h.Get("OpenAI-Organization") != tt.organization {
+					t.Errorf("%s: OpenAI-Organization = %q (present %v), want %q", path, h.Get("OpenAI-Organization"), ok, tt.organization)
+				}
+				if _, ok := h["Openai-Project"]; ok != (tt.project != "") || h.Get("OpenAI-Project") != tt.project {
+					t.Errorf("%s: OpenAI-Project = %q (present %v), want %q", path, h.Get("OpenAI-Project"), ok, tt.project)
+				}
+			}
+		})
+	}
+}
+
+// writeTestAPIError write the OpenAI error body with the status code
+func writeTestAPIError(w http.ResponseWriter, status int, code string) {
+	writeTestJSON(w, status, map[string]interface{}{
+		"error": map[string]interface{}{"message": "test error " + code, "type": "server_error", "code": code},
+	})
+}
+
+// writeTestChatContent write the chat completion response with one assistant message
+func writeTestChatContent(w http.ResponseWriter, content string) {
+	writeTestJSON(w, http.StatusOK, map[string]interface{}{
+		"id":      "chatcmpl-1",
+		"choices": []interface{}{map[string]interface{}{"message": map[string]interface{}{"role": "assistant", "content": content}}},
+	})
+}
+
+func TestRetryRetriableStatus(t *testing.T) {
+	for _, status := range []int{http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout} {
+		t.Run(strconv.Itoa(status), func(t *testing.T) {
+			clock := newFakeClock()
+			var bodies []string
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				bodies = append(bodies, string(body))
+				if len(bodies) < 3 {
+					writeTestAPIError(w, status, "retry_me")
+					return
+				}
+				writeTestChatContent(w, "ok")
+			}, WithRetry(3, 100*time.Millisecond), withClock(clock))
+
+			messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+			resp, err := client.OpenAISendMessage(&messages, false, nil, false, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if content, err := resp.Content(); err != nil || content != "ok" {
+				t.Errorf("content = %q, %v, want ok", content, err)
+			}
+
+			if len(bodies) != 3 {
+				t.Fatalf("requests = %d, want 3", len(bodies))
+			}
+			for i, body := range bodies {
+				if body == "" || body != bodies[0] {
+					t.Errorf("attempt %d body = %q, want the first attempt body %q", i+1, body, bodies[0])
+				}
+			}
+
+			// exponential backoff from 100ms with jitter on the second half of the max delay
+			slept := clock.Slept()
+			bounds := [][2]time.Duration{{50 * time.Millisecond, 100 * time.Millisecond}, {100 * time.Millisecond, 200 * time.Millisecond}}
+			if len(slept) != len(bounds) {
+				t.Fatalf("sleeps = %v, want %d", slept, len(bounds))
+			}
+			for i, d := range slept {
+				if d < bounds[i][0] || d > bounds[i][1] {
+					t.Errorf("sleep %d = %s, want in [%s, %s]", i+1, d, bounds[i][0], bounds[i][1])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryBackoffJitterBounds(t *testing.T) {
+	client := &openaiAPI{config: &Config{retryBaseDelay: time.Second}}
+
+	for attempt := 1; attempt <= 8; attempt++ {
+		max := client.maxRetryDelay(attempt)
+		if max > oaMaxRetryBackoff {
+			t.Fatalf("attempt %d: max delay %s exceed %s", attempt, max, oaMaxRetryBackoff)
+		}
+
+		for i := 0; i < 100; i++ {
+			if d := client.retryBackoff(attempt); d < max/2 || d > max {
+				t.Fatalf("attempt %d: backoff %s, want in [%s, %s]", attempt, d, max/2, max)
+			}
+		}
+	}
+}
+
+func TestRetryAfterHeaderHonored(t *testing.T) {
+	clock := newFakeClock()
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.Header().Set("Retry-After", "7")
+			writeTestAPIError(w, http.StatusTooManyRequests, "rate_limit_exceeded")
+			return
+		}
+		writeTestChatContent(w, "ok")
+	}, WithRetry(2, 100*time.Millisecond), withClock(clock))
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	if _, err := client.OpenAISendMessage(&messages, false, nil, false, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if slept := clock.Slept(); len(slept) != 1 || slept[0] != 7*time.Second {
+		t.Errorf("sleeps = %v, want [7s] from Retry-After", slept)
+	}
+}
+
+func TestRetryNonRetriableStatus(t *testing.T) {
+	clock := newFakeClock()
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeTestAPIError(w, http.StatusBadRequest, "invalid_request_error")
+	}, WithRetry(3, 100*time.Millisecond), withClock(clock))
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	_, err := client.OpenAISendMessage(&messages, false, nil, false, nil)
+
+	var apiErr *OAAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *OAAPIError, got %T: %v", err, err)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1", requests)
+	}
+	if apiErr.Attempts != 1 {
+		t.Errorf("Attempts = %d, want 1", apiErr.Attempts)
+	}
+	if slept := clock.Slept(); len(slept) != 0 {
+		t.Errorf("sleeps = %v, want none", slept)
+	}
+}
+
+func TestRetryExhaustedAttempts(t *testing.T) {
+	clock := newFakeClock()
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeTestAPIError(w, http.StatusServiceUnavailable, "server_error")
+	}, WithRetry(2, 100*time.Millisecond), withClock(clock))
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	_, err := client.OpenAISendMessage(&messages, false, nil, false, nil)
+
+	var apiErr *OAAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *OAAPIError, got %T: %v", err, err)
+	}
+	if requests != 3 {
+		t.Errorf("requests = %d, want 3", requests)
+	}
+	if apiErr.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", apiErr.Attempts)
+	}
+	if apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("StatusCode = %d, want %d", apiErr.StatusCode, http.StatusServiceUnavailable)
+	}
+}
+
+func TestRetryMultipartBodyResent(t *testing.T) {
+	clock := newFakeClock()
+	var files []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			t.Errorf("attempt %d: failed to read multipart file: %v", len(files)+1, err)
+			files = append(files, "")
+		} else {
+			data, _ := io.ReadAll(file)
+			files = append(files, string(data))
+		}
+
+		if len(files) == 1 {
+			writeTestAPIError(w, http.StatusInternalServerError, "server_error")
+			return
+		}
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"text": "hello"})
+	}, WithRetry(2, 100*time.Millisecond), withClock(clock))
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	writer.WriteField("model", "whisper-1")
+	part, _ := writer.CreateFormFile("file", "audio.mp3")
+	part.Write([]byte("fake-audio"))
+	writer.Close()
+
+	// the reader has no GetBody, so it must be buffered by the retry to send it again
+	if _, err := client.OpenAISpeechToTextRaw(io.MultiReader(&form), writer.FormDataContentType()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(files) != 2 {
+		t.Fatalf("requests = %d, want 2", len(files))
+	}
+	for i, file := range files {
+		if file != "fake-audio" {
+			t.Errorf("attempt %d file = %q, want fake-audio", i+1, file)
+		}
+	}
+}
+
+func TestRetryContextCancel(t *testing.T) {
+	clock := newFakeClock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeTestAPIError(w, http.StatusServiceUnavailable, "server_error")
+		w.(http.Flusher).Flush()
+		cancel()
+	}, WithRetry(5, 100*time.Millisecond), withClock(clock))
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	_, err := client.OpenAISendMessageContext(ctx, &messages, false, nil, false, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+	if requests != 1 {
+		t.Errorf("requests = %d, want 1 after the cancel", requests)
+	}
+}
+
+func TestRetryIfPredicate(t *testing.T) {
+	clock := newFakeClock()
+	codes := []string{"server_error", "context_length_exceeded"}
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		code := codes[requests]
+		requests++
+		writeTestAPIError(w, http.StatusBadRequest, code)
+	}, WithRetry(3, 100*time.Millisecond), withClock(clock), WithRetryIf(func(apiErr *OAAPIError) bool {
+		return apiErr.Code == "server_error"
+	}))
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	_, err := client.OpenAISendMessage(&messages, false, nil, false, nil)
+
+	var apiErr *OAAPIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *OAAPIError, got %T: %v", err, err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+	if apiErr.Code != "context_length_exceeded" || apiErr.Attempts != 2 {
+		t.Errorf("error = %q after %d attempts, want context_length_exceeded after 2", apiErr.Code, apiErr.Attempts)
+	}
+}
+
+func TestEffectiveTimeout(t *testing.T) {
+	// each model: 2 attempts of 10s and one max backoff wait of 1s
+	const perModel = 21 * time.Second
+
+	clock := newFakeClock()
+	tests := []struct {
+		name     string
+		opts     []ClientOption
+		model    string
+		deadline time.Duration
+		want     time.Duration
+	}{
+		{name: "default model with fallback", want: 2 * perModel},
+		{name: "request model not on fallback chain", model: "gpt-4.1", want: 3 * perModel},
+		{name: "request model is the last fallback", model: "gpt-4o-mini", want: 2 * perModel},
+		{name: "context trimming", opts: []ClientOption{WithContextTrimming(true)}, want: 4 * perModel},
+		{name: "context trimming with request model", opts: []ClientOption{WithContextTrimming(true)}, model: "gpt-4.1", want: 6 * perModel},
+		{name: "context deadline", deadline: 30 * time.Second, want: 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := append([]ClientOption{
+				WithHTTPClient(&http.Client{Timeout: 10 * time.Second}),
+				WithModel("gpt-4o"),
+				WithModelFallback("gpt-4o", "gpt-4o-mini"),
+				WithRetry(1, time.Second),
+				withClock(clock),
+			}, tt.opts...)
+
+			client, err := New("test-key", "", "", opts...)
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithDeadline(ctx, clock.Now().Add(tt.deadline))
+				defer cancel()
+			}
+
+			got, bounded := client.OpenAIEffectiveTimeout(ctx, tt.model)
+			if !bounded {
+				t.Fatal("bounded = false, want true")
+			}
+			if got != tt.want {
+				t.Errorf("OpenAIEffectiveTimeout() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolCallRoundTrip(t *testing.T) {
+	type weatherParams struct {
+		City string `json:"city" description:"City name"`
+	}
+
+	tool, err := OABuildTool("get_weather", "Get the current weather of a city", weatherParams{})
+	if err != nil {
+		t.Fatalf("Failed to build tool: %v", err)
+	}
+
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+
+		var reqBody struct {
+			Messages   []OAMessageReq `json:"messages"`
+			Tools      []OATool       `json:"tools"`
+			ToolChoice interface{}    `json:"tool_choice"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&reqBody); err != nil {
+			t.Errorf("request %d: failed to decode body: %v", requests, err)
+		}
+
+		if len(reqBody.Tools) != 1 || reqBody.Tools[0].Type != "function" || reqBody.Tools[0].Function.Name != "get_weather" {
+			t.Errorf("request %d: tools = %+v, want get_weather function", requests, reqBody.Tools)
+		}
+		if reqBody.ToolChoice != "auto" {
+			t.Errorf("request %d: tool_choice = %v, want auto", requests, reqBody.ToolChoice)
+		}
+
+		if requests == 1 {
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{
+				"id": "chatcmpl-1",
+				"choices": []interface{}{map[string]interface{}{
+					"finish_reason": "tool_calls",
+					"message": map[string]interface{}{
+						"role":    "assistant",
+						"content": nil,
+						"tool_calls": []interface{}{map[string]interface{}{
+							"id":       "call_1",
+							"type":     "function",
+							"function": map[string]interface{}{"name": "get_weather", "arguments": `{"city":"Jakarta"}`},
+						}},
+					},
+				}},
+			})
+			return
+		}
+
+		// the assistant tool calls message and the tool result must be sent back
+		if len(reqBody.Messages) != 3 {
+			t.Errorf("request %d: messages = %+v, want user, assistant, and tool", requests, reqBody.Messages)
+			writeTestAPIError(w, http.StatusBadRequest, "invalid_request_error")
+			return
+		}
+		assistant, result := reqBody.Messages[1], reqBody.Messages[2]
+		if assistant.Role != "assistant" || len(assistant.ToolCalls) != 1 || assistant.ToolCalls[0].ID != "call_1" {
+			t.Errorf("assistant message = %+v, want the call_1 tool call", assistant)
+		}
+		if result.Role != "tool" || result.ToolCallID != "call_1" || result.Content != `{"temp":31}` {
+			t.Errorf("tool message = %+v, want role tool with tool_call_id call_1", result)
+		}
+
+		writeTestChatContent(w, "It is 31 degrees in Jakarta.")
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "How is the weather in Jakarta?"}}
+	reqBody := OAReqBodyMessageCompletion{Messages: &messages, Tools: []OATool{tool}, ToolChoice: "auto"}
+
+	resp, err := client.OpenAISendMessage(&messages, false, nil, true, &reqBody)
+	if err != nil {
+		t.Fatalf("first request: unexpected error: %v", err)
+	}
+
+	message := resp.Choices[0].Message
+	if len(message.ToolCalls) != 1 {
+		t.Fatalf("tool calls = %+v, want 1", message.ToolCalls)
+	}
+
+	call := message.ToolCalls[0]
+	var args weatherParams
+	if err := json.Unmarshal([]byte(call.Function.Arguments), &args); err != nil {
+		t.Fatalf("Failed to decode arguments: %v", err)
+	}
+	if call.Function.Name != "get_weather" || args.City != "Jakarta" {
+		t.Errorf("call = %s(%+v), want get_weather(Jakarta)", call.Function.Name, args)
+	}
+
+	messages = append(messages, message.MessageReq())
+	messages = OAAppendToolResult(messages, call.ID, `{"temp":31}`)
+
+	resp, err = client.OpenAISendMessage(&messages, false, nil, true, &reqBody)
+	if err != nil {
+		t.Fatalf("second request: unexpected error: %v", err)
+	}
+	if content, err := resp.Content(); err != nil || content != "It is 31 degrees in Jakarta." {
+		t.Errorf("content = %q, %v, want the final answer", content, err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+}
+
+func TestSendMessagePerCallSamplingParams(t *testing.T) {
+	float := func(v float64) *float64 { return &v }
+	integer := func(v int) *int { return &v }
+
+	var lastBody map[string]interface{}
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		lastBody = nil
+		json.NewDecoder(r.Body).Decode(&lastBody)
+		writeTestChatContent(w, "ok")
+	}, WithMaxTokens(500), WithSamplingParams(OASamplingParams{Temperature: float(1.5), TopP: float(0.9), Seed: integer(7)}))
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+
+	t.Run("per call params override client defaults", func(t *testing.T) {
+		_, err := client.OpenAISendMessage(&messages, false, nil, false, nil, OASamplingParams{
+			Temperature:     float(0.2),
+			MaxTokens:       100,
+			N:               integer(2),
+			Stop:            []string{"END"},
+			PresencePenalty: float(0.5),
+			Seed:            integer(42),
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		want := map[string]interface{}{
+			"temperature":      0.2,
+			"top_p":            0.9,
+			"max_tokens":       float64(100),
+			"n":                float64(2),
+			"stop":             []interface{}{"END"},
+			"presence_penalty": 0.5,
+			"seed":             float64(42),
+		}
+		for field, value := range want {
+			if fmt.Sprint(lastBody[field]) != fmt.Sprint(value) {
+				t.Errorf("%s = %v, want %v", field, lastBody[field], value)
+			}
+		}
+	})
+
+	t.Run("client defaults without per call params", func(t *testing.T) {
+		if _, err := client.OpenAISendMessage(&messages, false, nil, false, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if lastBody["temperature"] != 1.5 || lastBody["max_tokens"] != float64(500) || lastBody["seed"] != float64(7) {
+			t.Errorf("body = %v, want the client temperature, max tokens, and seed", lastBody)
+		}
+		if _, ok := lastBody["n"]; ok {
+			t.Errorf("n = %v, want not sent", lastBody["n"])
+		}
+	})
+
+	t.Run("max completion tokens on reasoning model", func(t *testing.T) {
+		body := OAReqBodyMessageCompletion{Model: "o3-mini", Messages: &messages}
+		if _, err := client.OpenAISendMessage(nil, false, nil, true, &body, OASamplingParams{MaxCompletionTokens: 300}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if lastBody["max_completion_tokens"] != float64(300) {
+			t.Errorf("max_completion_tokens = %v, want 300", lastBody["max_completion_tokens"])
+		}
+		if _, ok := lastBody["max_tokens"]; ok {
+			t.Errorf("max_tokens = %v, want not sent", lastBody["max_tokens"])
+		}
+	})
+
+	t.Run("custom body field is kept", func(t *testing.T) {
+		body := OAReqBodyMessageCompletion{Messages: &messages, Temperature: float(0.7), MaxTokens: 50}
+		if _, err := client.OpenAISendMessage(nil, false, nil, true, &body, OASamplingParams{Temperature: float(0.2), MaxTokens: 100, TopP: float(0.3)}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if lastBody["temperature"] != 0.7 || lastBody["max_tokens"] != float64(50) || lastBody["top_p"] != 0.3 {
+			t.Errorf("body = %v, want custom temperature 0.7 and max tokens 50 with per call top_p 0.3", lastBody)
+		}
+	})
+
+	invalid := []struct {
+		name   string
+		params OASamplingParams
+	}{
+		{name: "temperature", params: OASamplingParams{Temperature: float(2.5)}},
+		{name: "top_p", params: OASamplingParams{TopP: float(1.5)}},
+		{name: "n", params: OASamplingParams{N: integer(0)}},
+		{name: "max_tokens", params: OASamplingParams{MaxTokens: -1}},
+		{name: "stop", params: OASamplingParams{Stop: []string{"a", "b", "c", "d", "e"}}},
+	}
+	for _, tt := range invalid {
+		t.Run("invalid "+tt.name, func(t *testing.T) {
+			before := requests
+			if _, err := client.OpenAISendMessage(&messages, false, nil, false, nil, tt.params); err == nil {
+				t.Fatalf("expected validation error for %+v, got nil", tt.params)
+			}
+			if requests != before {
+				t.Errorf("requests sent = %d, want 0", requests-before)
+			}
+		})
+	}
+}
+
+// waitForGoroutines wait until the number of goroutines is back to the baseline, so a goroutine leak fail the test
+func waitForGoroutines(t *testing.T, baseline int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<16)
+			t.Fatalf("goroutines = %d, want at most %d\n%s", runtime.NumGoroutine(), baseline, buf[:runtime.Stack(buf, true)])
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// testDetachedTransport send the request without the cancel of the request context, like a transport that does not stop
+// the body read on cancel, so only the client closing the body can stop a blocked read
+type testDetachedTransport struct {
+	next http.RoundTripper
+}
+
+func (t testDetachedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.Clone(context.WithoutCancel(req.Context())))
+}
+
+func TestSendMessageStreamCancelNoLeak(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, `data: {"id":"chatcmpl-1","choices":[{"index":0,"delta":{"role":"assistant","content":"Hello"}}]}`+"\n\n")
+		w.(http.Flusher).Flush()
+
+		// slow server, the next event never come before the client cancel
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release) // before srv.Close, so a handler still waiting does not block the close
+
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server url: %v", err)
+	}
+
+	transport := &http.Transport{}
+	defer transport.CloseIdleConnections()
+
+	client, err := New("test-key", "", "", WithHTTPClient(&http.Client{
+		Transport: testDetachedTransport{next: testRedirectTransport{target: target, next: transport}},
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	baseline := runtime.NumGoroutine()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+	stream, err := client.OpenAISendMessageStream(ctx, &messages, false, nil, false, nil)
+	if err != nil {
+		cancel()
+		t.Fatalf("OpenAISendMessageStream() error = %v", err)
+	}
+
+	chunk, ok := <-stream
+	if !ok || chunk.Content() != "Hello" {
+		cancel()
+		t.Fatalf("first chunk = %+v (open %v), want Hello", chunk, ok)
+	}
+
+	cancel()
+
+	// the reader blocked on the slow body must stop and close the channel without an error chunk
+	select {
+	case chunk, ok := <-stream:
+		if ok {
+			t.Fatalf("chunk after cancel = %+v, want closed channel", chunk)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream channel not closed after cancel")
+	}
+
+	transport.CloseIdleConnections()
+	waitForGoroutines(t, baseline)
+}
+
+func TestCollectStreamLogprobs(t *testing.T) {
+	var reqBody map[string]interface{}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&reqBody)
+		writeTestStream(w,
+			`{"id":"chatcmpl-3","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":""},"logprobs":null}]}`,
+			`{"id":"chatcmpl-3","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":"Hello"},"logprobs":{"content":[{"token":"Hello","logprob":-0.1,"bytes":[72,101,108,108,111],"top_logprobs":[{"token":"Hello","logprob":-0.1,"bytes":[72,101,108,108,111]},{"token":"Hi","logprob":-2.5,"bytes":[72,105]}]}],"refusal":null}}]}`,
+			`{"id":"chatcmpl-3","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"content":" world"},"logprobs":{"content":[{"token":" world","logprob":-0.3,"bytes":[32,119,111,114,108,100],"top_logprobs":[{"token":" world","logprob":-0.3,"bytes":[32,119,111,114,108,100]}]}],"refusal":null}}]}`,
+			`{"id":"chatcmpl-3","model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"logprobs":null,"finish_reason":"stop"}]}`,
+		)
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "say hello"}}
+	topLogprobs := 2
+	stream, err := client.OpenAISendMessageStream(context.Background(), nil, false, nil, true, &OAReqBodyMessageCompletion{
+		Messages:    &messages,
+		Logprobs:    true,
+		TopLogprobs: &topLogprobs,
+	})
+	if err != nil {
+		t.Fatalf("OpenAISendMessageStream() error = %v", err)
+	}
+
+	resp, err := OACollectStream(stream)
+	if err != nil {
+		t.Fatalf("OACollectStream() error = %v", err)
+	}
+
+	if reqBody["logprobs"] != true || reqBody["top_logprobs"] != float64(2) {
+		t.Errorf("request logprobs = %v, top_logprobs = %v, want true and 2", reqBody["logprobs"], reqBody["top_logprobs"])
+	}
+
+	choice := resp.Choices[0]
+	if choice.Message.Content != "Hello world" {
+		t.Errorf("content = %q, want Hello world", choice.Message.Content)
+	}
+	if choice.Logprobs == nil {
+		t.Fatal("Logprobs = nil, want the merged logprobs")
+	}
+
+	tokens := choice.Logprobs.Content
+	if len(tokens) != 2 || tokens[0].Token != "Hello" || tokens[1].Token != " world" {
+		t.Fatalf("logprobs tokens = %+v, want Hello and world in order", tokens)
+	}
+	if tokens[0].Logprob != -0.1 || len(tokens[0].TopLogprobs) != 2 || tokens[0].TopLogprobs[1].Token != "Hi" {
+		t.Errorf("first token logprobs = %+v, want -0.1 with Hi on top logprobs", tokens[0])
+	}
+	if string(rune(tokens[1].Bytes[1])) != "w" {
+		t.Errorf("second token bytes = %v, want the bytes of world", tokens[1].Bytes)
+	}
+}
+
+func TestSendMessageLogprobsDecode(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"chatcmpl-4","choices":[{"index":0,"message":{"role":"assistant","content":"Yes"},"logprobs":{"content":[{"token":"Yes","logprob":-0.01,"bytes":[89,101,115],"top_logprobs":[]}],"refusal":null},"finish_reason":"stop"}]}`))
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "yes or no?"}}
+	resp, err := client.OpenAISendMessage(nil, false, nil, true, &OAReqBodyMessageCompletion{Messages: &messages, Logprobs: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	logprobs := resp.Choices[0].Logprobs
+	if logprobs == nil || len(logprobs.Content) != 1 || logprobs.Content[0].Token != "Yes" || logprobs.Content[0].Logprob != -0.01 {
+		t.Errorf("Logprobs = %+v, want the Yes token", logprobs)
+	}
+
+	// top_logprobs without logprobs is rejected before sending
+	topLogprobs := 3
+	if _, err := client.OpenAISendMessage(nil, false, nil, true, &OAReqBodyMessageCompletion{Messages: &messages, TopLogprobs: &topLogprobs}); err == nil {
+		t.Error("expected error for TopLogprobs without Logprobs, got nil")
+	}
+}
+
+func TestOrganizationProjectPerCallOverride(t *testing.T) {
+	var mu sync.Mutex
+	var headers []http.Header
+	client := newTestClientOrg(t, "org-client", "proj-client", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		headers = append(headers, r.Header.Clone())
+		mu.Unlock()
+
+		if strings.HasSuffix(r.URL.Path, "/images/generations") {
+			writeTestJSON(w, http.StatusOK, map[string]interface{}{"created": 1, "data": []interface{}{map[string]interface{}{"url": "https://example.com/image.png"}}})
+			return
+		}
+		writeTestChatContent(w, "ok")
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "hello"}}
+
+	ctx := OAWithOrganizationProject(context.Background(), "org-call", "proj-call")
+	if _, err := client.OpenAISendMessageContext(ctx, &messages, false, nil, false, nil); err != nil {
+		t.Fatalf("chat with override: unexpected error: %v", err)
+	}
+
+	// empty value keep the client value
+	ctx = OAWithOrganizationProject(context.Background(), "", "proj-image")
+	if _, err := client.OpenAICreateImageDallEContext(ctx, &OAReqImageGeneratorDallE{Prompt: "a cat", Model: "dall-e-3"}); err != nil {
+		t.Fatalf("image with override: unexpected error: %v", err)
+	}
+
+	// the client is not changed by the override
+	if _, err := client.OpenAISendMessage(&messages, false, nil, false, nil); err != nil {
+		t.Fatalf("chat without override: unexpected error: %v", err)
+	}
+
+	want := [][2]string{
+		{"org-call", "proj-call"},
+		{"org-client", "proj-image"},
+		{"org-client", "proj-client"},
+	}
+	if len(headers) != len(want) {
+		t.Fatalf("requests = %d, want %d", len(headers), len(want))
+	}
+	for i, h := range headers {
+		if got := [2]string{h.Get("OpenAI-Organization"), h.Get("OpenAI-Project")}; got != want[i] {
+			t.Errorf("request %d: organization, project = %v, want %v", i+1, got, want[i])
+		}
+	}
+}
+
+func TestCollectStreamToolCalls(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		writeTestStream(w,
+			`{"id":"chatcmpl-5","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+			`{"id":"chatcmpl-5","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"ci"}}]}}]}`,
+			`{"id":"chatcmpl-5","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{\"zone\":"}}]}}]}`,
+			`{"id":"chatcmpl-5","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"ty\":\"Jakarta\"}"}}]}}]}`,
+			`{"id":"chatcmpl-5","model":"gpt-4o-mini","choices":[{"index":0,"delta":{"tool_calls":[{"index":1,"function":{"arguments":"\"WIB\"}"}}]}}]}`,
+			`{"id":"chatcmpl-5","model":"gpt-4o-mini","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		)
+	})
+
+	tool, err := OABuildTool("get_weather", "Get the current weather of a city", struct {
+		City string `json:"city"`
+	}{})
+	if err != nil {
+		t.Fatalf("Failed to build tool: %v", err)
+	}
+
+	messages := []OAMessageReq{{Role: "user", Content: "weather and time in Jakarta?"}}
+	stream, err := client.OpenAISendMessageStream(context.Background(), nil, false, nil, true, &OAReqBodyMessageCompletion{Messages: &messages, Tools: []OATool{tool}})
+	if err != nil {
+		t.Fatalf("OpenAISendMessageStream() error = %v", err)
+	}
+
+	resp, err := OACollectStream(stream)
+	if err != nil {
+		t.Fatalf("OACollectStream() error = %v", err)
+	}
+
+	choice := resp.Choices[0]
+	if choice.FinishReason != "tool_calls" {
+		t.Errorf("FinishReason = %q, want tool_calls", choice.FinishReason)
+	}
+
+	want := []OAToolCall{
+		{ID: "call_1", Type: "function", Function: OAToolCallFunction{Name: "get_weather", Arguments: `{"city":"Jakarta"}`}},
+		{ID: "call_2", Type: "function", Function: OAToolCallFunction{Name: "get_time", Arguments: `{"zone":"WIB"}`}},
+	}
+	if len(choice.Message.ToolCalls) != len(want) {
+		t.Fatalf("ToolCalls = %+v, want %d calls", choice.Message.ToolCalls, len(want))
+	}
+	for i, call := range choice.Message.ToolCalls {
+		if call != want[i] {
+			t.Errorf("call %d = %+v, want %+v", i, call, want[i])
+		}
+	}
+
+	// the collected message can be sent back like the non streaming response
+	if msg := choice.Message.MessageReq(); msg.Content != nil || len(msg.ToolCalls) != 2 {
+		t.Errorf("MessageReq() = %+v, want null content with 2 tool calls", msg)
+	}
+}
+
+func TestSendMessageStreamToRejectTools(t *testing.T) {
+	requests := 0
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		writeTestStream(w)
+	})
+
+	messages := []OAMessageReq{{Role: "user", Content: "weather in Jakarta?"}}
+	body := &OAReqBodyMessageCompletion{
+		Messages: &messages,
+		Tools:    []OATool{{Type: "function", Function: OAFunctionDef{Name: "get_weather"}}},
+	}
+
+	var out strings.Builder
+	if _, err := client.OpenAISendMessageStreamTo(context.Background(), &out, nil, false, nil, true, body); err == nil {
+		t.Fatal("expected error for Tools on OpenAISendMessageStreamTo, got nil")
+	}
+	if requests != 0 {
+		t.Errorf("requests = %d, want 0", requests)
+	}
+}
+
+// testCountingTransport count the requests sent through the client RoundTripper
+type testCountingTransport struct {
+	mu    sync.Mutex
+	count int
+	next  http.RoundTripper
+}
+
+func (t *testCountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.count++
+	t.mu.Unlock()
+
+	return t.next.RoundTrip(req)
+}
+
+func TestSpeechToTextUsesClientHTTPClient(t *testing.T) {
+	delay := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			<-delay
+		}
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"text": "hello"})
+	}))
+	defer srv.Close()
+	defer close(delay)
+
+	target, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse test server url: %v", err)
+	}
+
+	transport := &testCountingTransport{next: testRedirectTransport{target: target}}
+	client, err := New("test-key", "", "", WithHTTPClient(&http.Client{Transport: transport, Timeout: 200 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	writer.WriteField("model", "whisper-1")
+	part, _ := writer.CreateFormFile("file", "audio.mp3")
+	part.Write([]byte("fake-audio"))
+	writer.Close()
+
+	raw, err := client.OpenAISpeechToTextRaw(bytes.NewReader(form.Bytes()), writer.FormDataContentType())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(string(raw), "hello") {
+		t.Errorf("raw = %s, want the transcription", raw)
+	}
+	if transport.count != 1 {
+		t.Errorf("custom RoundTripper calls = %d, want 1", transport.count)
+	}
+
+	// the client Timeout apply to STT too, so a hung server does not block forever
+	slowClient, err := New("test-key", "", "", WithHTTPClient(&http.Client{
+		Transport: testQueryTransport{query: "slow=1", next: transport},
+		Timeout:   200 * time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := slowClient.OpenAISpeechToTextRaw(bytes.NewReader(form.Bytes()), writer.FormDataContentType()); err == nil {
+		t.Fatal("expected timeout error, got nil")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request took %s, want the 200ms client timeout", elapsed)
+	}
+	if transport.count != 2 {
+		t.Errorf("custom RoundTripper calls = %d, want 2", transport.count)
+	}
+}
+
+// testQueryTransport add the query to every request, so the test server can choose the response
+type testQueryTransport struct {
+	query string
+	next  http.RoundTripper
+}
+
+func (t testQueryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.URL.RawQuery = t.query
+
+	return t.next.RoundTrip(req)
+}
+
+func TestGetUsageHeadersAndBaseUrl(t *testing.T) {
+	var got *http.Request
+	client := newTestClientOrg(t, "org-test", "proj-test", func(w http.ResponseWriter, r *http.Request) {
+		got = r.Clone(context.Background())
+		writeTestJSON(w, http.StatusOK, map[string]interface{}{"object": "page", "data": []interface{}{}, "has_more": false})
+	}, WithAdminKey("admin-key"), WithBaseUrl("https://gateway.example.com/openai/v1/chat/completions"))
+
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := client.OpenAIGetUsage(context.Background(), start, start.AddDate(0, 0, 7)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.URL.Path != "/openai/v1/organization/usage/completions" {
+		t.Errorf("path = %q, want the usage endpoint on the WithBaseUrl root", got.URL.Path)
+	}
+	if auth := got.Header.Get("Authorization"); auth != "Bearer admin-key" {
+		t.Errorf("Authorization = %q, want the admin key", auth)
+	}
+	if got.Header.Get("OpenAI-Organization") != "org-test" || got.Header.Get("OpenAI-Project") != "proj-test" {
+		t.Errorf("organization, project = %q, %q, want org-test, proj-test", got.Header.Get("OpenAI-Organization"), got.Header.Get("OpenAI-Project"))
+	}
+}
+
+func TestVerifyNoMissingDefaultRequired(t *testing.T) {
+	type recipe struct {
+		Name     string `json:"name"`
+		Servings *int   `json:"servings"`
+		Note     string `json:"note,omitempty"`
+	}
+
+	servings := 0
+	if err := OAVerifyNoMissing(recipe{Name: "soup", Servings: &servings}, nil); err != nil {
+		t.Errorf("unexpected error with zero value behind the pointer: %v", err)
+	}
+
+	// pointer field is required by default, unlike OABuildTool, and omitempty field is not
+	err := OAVerifyNoMissing(&recipe{Name: "soup"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "servings") || strings.Contains(err.Error(), "note") {
+		t.Errorf("error = %v, want only servings reported", err)
+	}
+
+	tool, err := OABuildTool("save_recipe", "Save a recipe", recipe{})
+	if err != nil {
+		t.Fatalf("Failed to build tool: %v", err)
+	}
+	if required := fmt.Sprint(tool.Function.Parameters["required"]); required != "[name]" {
+		t.Errorf("OABuildTool required = %s, want [name]", required)
+	}
+}
+
+func TestSchemaByteSliceIsString(t *testing.T) {
+	type attachment struct {
+		Data     []byte   `json:"data"`
+		Checksum [4]byte  `json:"checksum"`
+		Chunks   [][]byte `json:"chunks"`
+	}
+
+	_, schema, err := OASchemaFromStruct(attachment{})
+	if err != nil {
+		t.Fatalf("OASchemaFromStruct() error = %v", err)
+	}
+
+	properties := schema["properties"].(map[string]interface{})
+	if got := fmt.Sprint(properties["data"]); got != "map[type:string]" {
+		t.Errorf("data schema = %s, want string", got)
+	}
+	if got := fmt.Sprint(properties["checksum"]); got != "map[items:map[type:integer] type:array]" {
+		t.Errorf("checksum schema = %s, want array of integer", got)
+	}
+	if got := fmt.Sprint(properties["chunks"]); got != "map[items:map[type:string] type:array]" {
+		t.Errorf("chunks schema = %s, want array of string", got)
+	}
+
+	// the schema must match what encoding/json produce for the struct
+	encoded, _ := json.Marshal(attachment{Data: []byte("hi")})
+	if !strings.Contains(string(encoded), `"data":"aGk="`) {
+		t.Errorf("encoded = %s, want base64 string for data", encoded)
+	}
+}